@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisKeyPrefix = "artist:img:"
+
+/// Cache abstracts artist image cache storage so the service can run against a single
+/// SQLite file or a shared Redis cache with SQLite as the durable fallback
+type Cache interface {
+	Get(ctx context.Context, artistName string) (*CachedImage, error)
+	Set(ctx context.Context, cached *CachedImage) error
+	Count(ctx context.Context) (int, error)
+	NegativeCount(ctx context.Context) (int, error)
+}
+
+/// Locker lets a Cache implementation coordinate concurrent upstream fetches for the
+/// same artist across service replicas. Not every Cache implements it. AcquireLock
+/// returns a fencing token that must be passed back to ReleaseLock, so a holder whose
+/// work outlives the lock's TTL can't delete a lock a different replica has since
+/// acquired
+type Locker interface {
+	AcquireLock(ctx context.Context, artistName string, ttl time.Duration) (token string, acquired bool, err error)
+	ReleaseLock(ctx context.Context, artistName, token string) error
+}
+
+/// SQLiteCache is the original, single-instance cache backed by the artist_images table
+type SQLiteCache struct {
+	db *sql.DB
+}
+
+func NewSQLiteCache(db *sql.DB) *SQLiteCache {
+	return &SQLiteCache{db: db}
+}
+
+func (c *SQLiteCache) Get(ctx context.Context, artistName string) (cached *CachedImage, err error) {
+	ctx, span := startSpan(ctx, "sqlite.Get")
+	defer func() { endSpan(span, err) }()
+
+	cacheKey := strings.ToLower(strings.TrimSpace(artistName))
+
+	var row CachedImage
+	var fetchedAtUnix int64
+	var negative int
+
+	err = c.db.QueryRowContext(ctx, `
+		SELECT artist_name, image_key, url, source, fetched_at, negative
+		FROM artist_images
+		WHERE artist_name_lower = ?
+	`, cacheKey).Scan(&row.ArtistName, &row.ImageKey, &row.URL, &row.Source, &fetchedAtUnix, &negative)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	row.FetchedAt = time.Unix(fetchedAtUnix, 0)
+	row.Negative = negative != 0
+	return &row, nil
+}
+
+func (c *SQLiteCache) Set(ctx context.Context, cached *CachedImage) (err error) {
+	ctx, span := startSpan(ctx, "sqlite.Set")
+	defer func() { endSpan(span, err) }()
+
+	cacheKey := strings.ToLower(strings.TrimSpace(cached.ArtistName))
+
+	negative := 0
+	if cached.Negative {
+		negative = 1
+	}
+
+	_, err = c.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO artist_images (artist_name_lower, artist_name, image_key, url, source, fetched_at, negative)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, cacheKey, cached.ArtistName, cached.ImageKey, cached.URL, cached.Source, cached.FetchedAt.Unix(), negative)
+
+	return err
+}
+
+func (c *SQLiteCache) Count(ctx context.Context) (int, error) {
+	var count int
+	err := c.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM artist_images").Scan(&count)
+	return count, err
+}
+
+func (c *SQLiteCache) NegativeCount(ctx context.Context) (int, error) {
+	var count int
+	err := c.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM artist_images WHERE negative = 1").Scan(&count)
+	return count, err
+}
+
+/// RedisCache fronts a SQLiteCache with a shared Redis cache so multiple replicas see
+/// the same lookups and coordinate upstream fetches via a per-artist lock. Reads and
+/// writes fall back to the SQLite store whenever Redis is unreachable
+type RedisCache struct {
+	client   *redis.Client
+	fallback *SQLiteCache
+	ttl      time.Duration
+}
+
+func NewRedisCache(client *redis.Client, fallback *SQLiteCache, ttl time.Duration) *RedisCache {
+	return &RedisCache{client: client, fallback: fallback, ttl: ttl}
+}
+
+func (c *RedisCache) redisKey(artistName string) string {
+	return redisKeyPrefix + strings.ToLower(strings.TrimSpace(artistName))
+}
+
+func (c *RedisCache) Get(ctx context.Context, artistName string) (*CachedImage, error) {
+	data, err := c.client.Get(ctx, c.redisKey(artistName)).Bytes()
+	if err == nil {
+		var cached CachedImage
+		if jsonErr := json.Unmarshal(data, &cached); jsonErr == nil {
+			return &cached, nil
+		}
+	} else if err != redis.Nil {
+		slog.Warn("redis GET failed, falling back to sqlite", "error", err)
+	}
+
+	//Redis miss or unreachable => fall back to the durable SQLite store
+	cached, err := c.fallback.Get(ctx, artistName)
+	if err != nil || cached == nil {
+		return cached, err
+	}
+
+	//Best-effort warm of Redis so subsequent reads avoid the SQLite round trip
+	c.writeRedis(ctx, cached)
+	return cached, nil
+}
+
+func (c *RedisCache) Set(ctx context.Context, cached *CachedImage) error {
+	if err := c.fallback.Set(ctx, cached); err != nil {
+		return err
+	}
+	c.writeRedis(ctx, cached)
+	return nil
+}
+
+func (c *RedisCache) writeRedis(ctx context.Context, cached *CachedImage) {
+	ttl := c.ttl
+	data, err := json.Marshal(cached)
+	if err != nil {
+		slog.Warn("failed to marshal cache entry for redis", "error", err)
+		return
+	}
+	if err := c.client.Set(ctx, c.redisKey(cached.ArtistName), data, ttl).Err(); err != nil {
+		slog.Warn("redis SET failed, relying on sqlite fallback", "error", err)
+	}
+}
+
+func (c *RedisCache) Count(ctx context.Context) (int, error) {
+	//Redis doesn't cheaply enumerate matching keys at scale, so stats report the
+	//durable SQLite count, which every write passes through
+	return c.fallback.Count(ctx)
+}
+
+func (c *RedisCache) NegativeCount(ctx context.Context) (int, error) {
+	return c.fallback.NegativeCount(ctx)
+}
+
+//releaseLockScript deletes the lock key only if it still holds the caller's own
+//fencing token, so a holder releasing after its TTL expired can't delete a lock a
+//different replica has since acquired
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+/// AcquireLock takes a short-lived per-artist lock via Redis SETNX, storing a random
+/// fencing token as the value so ReleaseLock can prove ownership before deleting it
+func (c *RedisCache) AcquireLock(ctx context.Context, artistName string, ttl time.Duration) (string, bool, error) {
+	lockKey := "artist:lock:" + strings.ToLower(strings.TrimSpace(artistName))
+	token := newLockToken()
+
+	acquired, err := c.client.SetNX(ctx, lockKey, token, ttl).Result()
+	if err != nil {
+		return "", false, err
+	}
+	return token, acquired, nil
+}
+
+/// ReleaseLock releases the per-artist lock ahead of its TTL once the fetch completes,
+/// but only if it still holds the token returned by AcquireLock
+func (c *RedisCache) ReleaseLock(ctx context.Context, artistName, token string) error {
+	lockKey := "artist:lock:" + strings.ToLower(strings.TrimSpace(artistName))
+	return releaseLockScript.Run(ctx, c.client, []string{lockKey}, token).Err()
+}
+
+//lockTokenFallback counts tokens handed out when crypto/rand is unavailable, so the
+//fallback path still yields a value unique within this process
+var lockTokenFallback atomic.Int64
+
+//newLockToken generates a random fencing token to identify this lock acquisition
+func newLockToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		//crypto/rand failing is effectively unheard of; fall back to a value that's
+		//still unique per-process so locking degrades rather than panics
+		return fmt.Sprintf("fallback-%d-%d", time.Now().UnixNano(), lockTokenFallback.Add(1))
+	}
+	return hex.EncodeToString(b)
+}
+
+//waitForCache polls the cache for another replica's in-flight fetch to land,
+//giving up once pollTimeout has elapsed. A Negative entry means the holder
+//finished and genuinely found nothing, so it's reported as the same
+//not-found error a direct lookup would give, not returned as if it were a
+//successful result
+func waitForCache(ctx context.Context, cache Cache, artistName string, positiveCacheTTL, pollTimeout time.Duration) (*CachedImage, error) {
+	deadline := time.Now().Add(pollTimeout)
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+			cached, err := cache.Get(ctx, artistName)
+			if err != nil || cached == nil {
+				continue
+			}
+			if cached.Negative {
+				//Only a negative entry written within the wait window can come from the
+				//holder's fetch; an older one predates it and is ignored
+				if time.Since(cached.FetchedAt) < pollTimeout {
+					return nil, fmt.Errorf("no artist results found")
+				}
+				continue
+			}
+			if time.Since(cached.FetchedAt) < positiveCacheTTL {
+				return cached, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("timed out waiting for concurrent fetch of %q", artistName)
+}
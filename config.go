@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/// ProviderSetting configures a single entry in the provider chain
+type ProviderSetting struct {
+	Name       string `json:"name"`
+	Enabled    bool   `json:"enabled"`
+	TimeoutMS  int    `json:"timeout_ms"`
+	MinQuality int    `json:"min_quality"`
+}
+
+/// Config holds the provider chain and credentials used to build an ArtistImageService
+type Config struct {
+	Providers        []ProviderSetting `json:"providers"`
+	NegativeCacheTTL time.Duration     `json:"-"`
+	PositiveCacheTTL time.Duration     `json:"-"`
+
+	SpotifyClientID      string `json:"-"`
+	SpotifyClientSecret  string `json:"-"`
+	LastFMAPIKey         string `json:"-"`
+	MusicBrainzUserAgent string `json:"-"`
+
+	RedisAddr     string `json:"-"`
+	RedisPassword string `json:"-"`
+	RedisDB       int    `json:"-"`
+
+	StorageBackend string `json:"-"`
+
+	MinIOEndpoint       string `json:"-"`
+	MinIOAccessKey      string `json:"-"`
+	MinIOSecretKey      string `json:"-"`
+	MinIOBucket         string `json:"-"`
+	MinIOUseSSL         bool   `json:"-"`
+	MinIOPublicEndpoint string `json:"-"`
+
+	S3Region        string `json:"-"`
+	S3Bucket        string `json:"-"`
+	S3PublicBaseURL string `json:"-"`
+
+	COSBucketURL string `json:"-"`
+	COSSecretID  string `json:"-"`
+	COSSecretKey string `json:"-"`
+
+	OSSEndpoint        string `json:"-"`
+	OSSAccessKeyID     string `json:"-"`
+	OSSAccessKeySecret string `json:"-"`
+	OSSBucket          string `json:"-"`
+
+	LocalFSBaseDir       string `json:"-"`
+	LocalFSPublicBaseURL string `json:"-"`
+
+	UploadAPIKey       string `json:"-"`
+	MaxUploadSizeBytes int64  `json:"-"`
+
+	ProviderRateLimitRPS   float64  `json:"-"`
+	ProviderRateLimitBurst int      `json:"-"`
+	ClientRateLimitRPS     float64  `json:"-"`
+	ClientRateLimitBurst   int      `json:"-"`
+	TrustedProxyCIDRs      []string `json:"-"`
+}
+
+//configFile mirrors Config but with JSON-friendly duration fields
+type configFile struct {
+	Providers           []ProviderSetting `json:"providers"`
+	NegativeCacheTTLSec int               `json:"negative_cache_ttl_seconds"`
+	PositiveCacheTTLSec int               `json:"positive_cache_ttl_seconds"`
+}
+
+//defaultProviders is used when no config file is present
+func defaultProviders() []ProviderSetting {
+	return []ProviderSetting{
+		{Name: "deezer", Enabled: true, TimeoutMS: 10000, MinQuality: 0},
+		{Name: "spotify", Enabled: true, TimeoutMS: 8000, MinQuality: 0},
+		{Name: "musicbrainz", Enabled: true, TimeoutMS: 8000, MinQuality: 0},
+		{Name: "lastfm", Enabled: true, TimeoutMS: 8000, MinQuality: 0},
+		{Name: "wikipedia", Enabled: true, TimeoutMS: 8000, MinQuality: 0},
+	}
+}
+
+/// LoadConfig reads provider chain settings from a JSON file (if present) and layers
+/// API credentials on top from environment variables
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{
+		Providers:        defaultProviders(),
+		NegativeCacheTTL: time.Hour,
+		PositiveCacheTTL: 7 * 24 * time.Hour,
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			var cf configFile
+			if err := json.Unmarshal(data, &cf); err != nil {
+				return nil, err
+			}
+			if len(cf.Providers) > 0 {
+				cfg.Providers = cf.Providers
+			}
+			if cf.NegativeCacheTTLSec > 0 {
+				cfg.NegativeCacheTTL = time.Duration(cf.NegativeCacheTTLSec) * time.Second
+			}
+			if cf.PositiveCacheTTLSec > 0 {
+				cfg.PositiveCacheTTL = time.Duration(cf.PositiveCacheTTLSec) * time.Second
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	cfg.SpotifyClientID = os.Getenv("SPOTIFY_CLIENT_ID")
+	cfg.SpotifyClientSecret = os.Getenv("SPOTIFY_CLIENT_SECRET")
+	cfg.LastFMAPIKey = os.Getenv("LASTFM_API_KEY")
+	cfg.MusicBrainzUserAgent = os.Getenv("MUSICBRAINZ_USER_AGENT")
+	if cfg.MusicBrainzUserAgent == "" {
+		cfg.MusicBrainzUserAgent = "artist-image-service/1.0 ( https://github.com/appsono/artist-image-service )"
+	}
+
+	cfg.RedisAddr = os.Getenv("REDIS_ADDR")
+	cfg.RedisPassword = os.Getenv("REDIS_PASSWORD")
+	cfg.RedisDB = 0
+	if dbStr := os.Getenv("REDIS_DB"); dbStr != "" {
+		if v, err := strconv.Atoi(dbStr); err == nil {
+			cfg.RedisDB = v
+		}
+	}
+
+	cfg.StorageBackend = os.Getenv("STORAGE_BACKEND")
+	if cfg.StorageBackend == "" {
+		cfg.StorageBackend = "minio"
+	}
+
+	cfg.MinIOEndpoint = os.Getenv("MINIO_ENDPOINT")
+	cfg.MinIOAccessKey = os.Getenv("MINIO_ACCESS_KEY")
+	cfg.MinIOSecretKey = os.Getenv("MINIO_SECRET_KEY")
+	cfg.MinIOBucket = os.Getenv("MINIO_BUCKET")
+	cfg.MinIOUseSSL = os.Getenv("MINIO_USE_SSL") == "true"
+	cfg.MinIOPublicEndpoint = os.Getenv("MINIO_PUBLIC_ENDPOINT")
+
+	cfg.S3Region = os.Getenv("S3_REGION")
+	cfg.S3Bucket = os.Getenv("S3_BUCKET")
+	cfg.S3PublicBaseURL = os.Getenv("S3_PUBLIC_BASE_URL")
+
+	cfg.COSBucketURL = os.Getenv("COS_BUCKET_URL")
+	cfg.COSSecretID = os.Getenv("COS_SECRET_ID")
+	cfg.COSSecretKey = os.Getenv("COS_SECRET_KEY")
+
+	cfg.OSSEndpoint = os.Getenv("OSS_ENDPOINT")
+	cfg.OSSAccessKeyID = os.Getenv("OSS_ACCESS_KEY_ID")
+	cfg.OSSAccessKeySecret = os.Getenv("OSS_ACCESS_KEY_SECRET")
+	cfg.OSSBucket = os.Getenv("OSS_BUCKET")
+
+	cfg.LocalFSBaseDir = os.Getenv("LOCALFS_BASE_DIR")
+	if cfg.LocalFSBaseDir == "" {
+		cfg.LocalFSBaseDir = "./data/objects"
+	}
+	cfg.LocalFSPublicBaseURL = os.Getenv("LOCALFS_PUBLIC_BASE_URL")
+	if cfg.LocalFSPublicBaseURL == "" {
+		cfg.LocalFSPublicBaseURL = "http://localhost:8080/static"
+	}
+
+	cfg.UploadAPIKey = os.Getenv("UPLOAD_API_KEY")
+	cfg.MaxUploadSizeBytes = 50 * 1024 * 1024
+	if sizeStr := os.Getenv("MAX_UPLOAD_SIZE_BYTES"); sizeStr != "" {
+		if v, err := strconv.ParseInt(sizeStr, 10, 64); err == nil && v > 0 {
+			cfg.MaxUploadSizeBytes = v
+		}
+	}
+
+	cfg.ProviderRateLimitRPS = 2
+	if v := os.Getenv("PROVIDER_RATE_LIMIT_RPS"); v != "" {
+		if parsed, err := strconv.ParseFloat(v, 64); err == nil && parsed > 0 {
+			cfg.ProviderRateLimitRPS = parsed
+		}
+	}
+	cfg.ProviderRateLimitBurst = 4
+	if v := os.Getenv("PROVIDER_RATE_LIMIT_BURST"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			cfg.ProviderRateLimitBurst = parsed
+		}
+	}
+
+	cfg.ClientRateLimitRPS = 5
+	if v := os.Getenv("CLIENT_RATE_LIMIT_RPS"); v != "" {
+		if parsed, err := strconv.ParseFloat(v, 64); err == nil && parsed > 0 {
+			cfg.ClientRateLimitRPS = parsed
+		}
+	}
+	cfg.ClientRateLimitBurst = 10
+	if v := os.Getenv("CLIENT_RATE_LIMIT_BURST"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			cfg.ClientRateLimitBurst = parsed
+		}
+	}
+
+	if v := os.Getenv("TRUSTED_PROXY_CIDRS"); v != "" {
+		for _, cidr := range strings.Split(v, ",") {
+			if cidr = strings.TrimSpace(cidr); cidr != "" {
+				cfg.TrustedProxyCIDRs = append(cfg.TrustedProxyCIDRs, cidr)
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+//BucketName reports the configured bucket name for the active storage backend, for
+//display purposes only (e.g. /api/stats)
+func (c *Config) BucketName() string {
+	switch c.StorageBackend {
+	case "s3":
+		return c.S3Bucket
+	case "cos":
+		return c.COSBucketURL
+	case "oss":
+		return c.OSSBucket
+	case "local":
+		return c.LocalFSBaseDir
+	default:
+		return c.MinIOBucket
+	}
+}
+
+/// NewObjectStore builds the ObjectStore selected by cfg.StorageBackend
+func NewObjectStore(ctx context.Context, cfg *Config) (ObjectStore, error) {
+	switch cfg.StorageBackend {
+	case "s3":
+		return NewS3Store(ctx, cfg.S3Region, cfg.S3Bucket, cfg.S3PublicBaseURL)
+	case "cos":
+		return NewCOSStore(cfg.COSBucketURL, cfg.COSSecretID, cfg.COSSecretKey)
+	case "oss":
+		return NewOSSStore(cfg.OSSEndpoint, cfg.OSSAccessKeyID, cfg.OSSAccessKeySecret, cfg.OSSBucket)
+	case "local":
+		return NewLocalFSStore(cfg.LocalFSBaseDir, cfg.LocalFSPublicBaseURL)
+	case "minio", "":
+		return NewMinIOStore(cfg.MinIOEndpoint, cfg.MinIOAccessKey, cfg.MinIOSecretKey, cfg.MinIOBucket, cfg.MinIOPublicEndpoint, cfg.MinIOUseSSL)
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q", cfg.StorageBackend)
+	}
+}
+
+/// BuildProviders instantiates enabled providers from the config, in configured order
+func (c *Config) BuildProviders() []ImageProvider {
+	providers := make([]ImageProvider, 0, len(c.Providers))
+
+	for _, p := range c.Providers {
+		if !p.Enabled {
+			continue
+		}
+
+		timeout := time.Duration(p.TimeoutMS) * time.Millisecond
+		if timeout <= 0 {
+			timeout = 8 * time.Second
+		}
+
+		switch p.Name {
+		case "deezer":
+			providers = append(providers, &DeezerProvider{FetchTimeout: timeout, MinQuality: p.MinQuality})
+		case "spotify":
+			if c.SpotifyClientID == "" || c.SpotifyClientSecret == "" {
+				continue
+			}
+			providers = append(providers, &SpotifyProvider{
+				ClientID:     c.SpotifyClientID,
+				ClientSecret: c.SpotifyClientSecret,
+				FetchTimeout: timeout,
+				MinQuality:   p.MinQuality,
+			})
+		case "musicbrainz":
+			providers = append(providers, &MusicBrainzProvider{UserAgent: c.MusicBrainzUserAgent, FetchTimeout: timeout, MinQuality: p.MinQuality})
+		case "lastfm":
+			if c.LastFMAPIKey == "" {
+				continue
+			}
+			providers = append(providers, &LastFMProvider{APIKey: c.LastFMAPIKey, FetchTimeout: timeout, MinQuality: p.MinQuality})
+		case "wikipedia":
+			providers = append(providers, &WikipediaProvider{FetchTimeout: timeout, MinQuality: p.MinQuality})
+		}
+	}
+
+	return providers
+}
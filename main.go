@@ -5,9 +5,11 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"strings"
@@ -16,17 +18,21 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 	_ "github.com/mattn/go-sqlite3"
-	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/singleflight"
 )
 
-/// CachedImage represents metadata about a cached artist image
+/// CachedImage represents metadata about a cached artist image. A Negative entry
+/// records a prior failed lookup (no URL) so repeated misses don't hammer upstreams
 type CachedImage struct {
 	ArtistName string    `json:"artist_name"`
 	ImageKey   string    `json:"image_key"`
 	URL        string    `json:"url"`
 	Source     string    `json:"source"`
 	FetchedAt  time.Time `json:"fetched_at"`
+	Negative   bool      `json:"negative"`
 }
 
 /// APIResponse is the JSON response structure
@@ -41,13 +47,29 @@ type APIResponse struct {
 
 /// ArtistImageService handles artist image operations
 type ArtistImageService struct {
-	db          *sql.DB
-	minioClient *minio.Client
-	bucket      string
+	db             *sql.DB
+	cache          Cache
+	store          ObjectStore
+	bucketName     string
+	storageBackend string
+	providers      []ImageProvider
+
+	negativeCacheTTL time.Duration
+	positiveCacheTTL time.Duration
+	lockTTL          time.Duration
+	lockWaitTimeout  time.Duration
+
+	uploadAPIKey       string
+	maxUploadSizeBytes int64
+
+	rateLimiter *RateLimiter
+	fetchGroup  singleflight.Group
 }
 
-/// NewArtistImageService creates a new service instance
-func NewArtistImageService(dbPath, minioEndpoint, accessKey, secretKey, bucket string, useSSL bool) (*ArtistImageService, error) {
+/// NewArtistImageService creates a new service instance. The object store is built
+/// ahead of time by NewObjectStore so storage backend setup (bucket creation, policy)
+/// stays local to each backend's constructor
+func NewArtistImageService(dbPath string, store ObjectStore, cfg *Config) (*ArtistImageService, error) {
 	//Initialize SQLite database
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
@@ -62,209 +84,125 @@ func NewArtistImageService(dbPath, minioEndpoint, accessKey, secretKey, bucket s
 			image_key TEXT NOT NULL,
 			url TEXT NOT NULL,
 			source TEXT NOT NULL,
-			fetched_at INTEGER NOT NULL
+			fetched_at INTEGER NOT NULL,
+			negative INTEGER NOT NULL DEFAULT 0
 		)
 	`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create table: %w", err)
 	}
 
-	//Initialize MinIO client
-	minioClient, err := minio.New(minioEndpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
-		Secure: useSSL,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to create MinIO client: %w", err)
-	}
+	//negative column is added via ALTER TABLE for databases created before this field existed
+	_, _ = db.Exec(`ALTER TABLE artist_images ADD COLUMN negative INTEGER NOT NULL DEFAULT 0`)
 
-	//Create bucket if not exists
-	ctx := context.Background()
-	exists, err := minioClient.BucketExists(ctx, bucket)
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS upload_sessions (
+			id TEXT PRIMARY KEY,
+			artist_name TEXT NOT NULL,
+			total_length INTEGER NOT NULL,
+			received_length INTEGER NOT NULL DEFAULT 0,
+			content_type TEXT NOT NULL,
+			part_keys TEXT NOT NULL DEFAULT '',
+			created_at INTEGER NOT NULL
+		)
+	`)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check bucket: %w", err)
+		return nil, fmt.Errorf("failed to create upload_sessions table: %w", err)
 	}
 
-	if !exists {
-		err = minioClient.MakeBucket(ctx, bucket, minio.MakeBucketOptions{})
-		if err != nil {
-			return nil, fmt.Errorf("failed to create bucket: %w", err)
-		}
-		log.Printf("Created MinIO bucket: %s\n", bucket)
+	if cfg == nil {
+		cfg = &Config{Providers: defaultProviders(), NegativeCacheTTL: time.Hour, PositiveCacheTTL: 7 * 24 * time.Hour, StorageBackend: "minio"}
 	}
 
-	//Set bucket to public read policy
-	policy := fmt.Sprintf(`{
-		"Version": "2012-10-17",
-		"Statement": [{
-			"Effect": "Allow",
-			"Principal": {"AWS": ["*"]},
-			"Action": ["s3:GetObject"],
-			"Resource": ["arn:aws:s3:::%s/*"]
-		}]
-	}`, bucket)
+	sqliteCache := NewSQLiteCache(db)
+	cache := buildCache(cfg, sqliteCache)
 
-	err = minioClient.SetBucketPolicy(ctx, bucket, policy)
-	if err != nil {
-		log.Printf("Warning: failed to set bucket policy: %v\n", err)
-	}
+	providers := cfg.BuildProviders()
+
+	//The lock must outlive the worst case of walking the whole provider chain (every
+	//provider timing out) plus upload/cache overhead, or it can expire mid-fetch and
+	//let a second replica acquire it while the first is still working. Waiters in turn
+	//need to wait at least that long, or they give up before the holder could possibly
+	//have finished
+	lockTTL := providerTimeoutSum(providers) + 10*time.Second
+	lockWaitTimeout := lockTTL + 5*time.Second
 
 	service := &ArtistImageService{
-		db:          db,
-		minioClient: minioClient,
-		bucket:      bucket,
+		db:               db,
+		cache:            cache,
+		store:            store,
+		bucketName:       cfg.BucketName(),
+		storageBackend:   cfg.StorageBackend,
+		providers:        providers,
+		negativeCacheTTL: cfg.NegativeCacheTTL,
+		positiveCacheTTL: cfg.PositiveCacheTTL,
+		lockTTL:          lockTTL,
+		lockWaitTimeout:  lockWaitTimeout,
+
+		uploadAPIKey:       cfg.UploadAPIKey,
+		maxUploadSizeBytes: cfg.MaxUploadSizeBytes,
+
+		rateLimiter: NewRateLimiter(cfg),
 	}
 
 	//Load existing cache count
-	count, _ := service.getCacheCount()
-	log.Printf("Loaded %d cached artist images from database\n", count)
+	count, _ := service.cache.Count(context.Background())
+	slog.Info("loaded cached artist images from database", "count", count)
+	slog.Info("storage backend configured", "backend", cfg.StorageBackend, "bucket", service.bucketName)
+	slog.Info("provider chain configured", "chain", providerNames(service.providers))
 
 	return service, nil
 }
 
-/// Close closes the database connection
-func (s *ArtistImageService) Close() error {
-	return s.db.Close()
-}
-
-/// getCacheCount returns the number of cached images
-func (s *ArtistImageService) getCacheCount() (int, error) {
-	var count int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM artist_images").Scan(&count)
-	return count, err
-}
-
-/// getCachedImage retrieves cached image metadata from database
-func (s *ArtistImageService) getCachedImage(artistName string) (*CachedImage, error) {
-	cacheKey := strings.ToLower(strings.TrimSpace(artistName))
-
-	var cached CachedImage
-	var fetchedAtUnix int64
-
-	err := s.db.QueryRow(`
-		SELECT artist_name, image_key, url, source, fetched_at
-		FROM artist_images
-		WHERE artist_name_lower = ?
-	`, cacheKey).Scan(&cached.ArtistName, &cached.ImageKey, &cached.URL, &cached.Source, &fetchedAtUnix)
-
-	if err == sql.ErrNoRows {
-		return nil, nil
+//buildCache wraps the SQLite cache with a Redis front, when REDIS_ADDR is configured
+func buildCache(cfg *Config, sqliteCache *SQLiteCache) Cache {
+	if cfg.RedisAddr == "" {
+		return sqliteCache
 	}
-	if err != nil {
-		return nil, err
-	}
-
-	cached.FetchedAt = time.Unix(fetchedAtUnix, 0)
-	return &cached, nil
-}
-
-/// saveCachedImage saves image metadata to database
-func (s *ArtistImageService) saveCachedImage(cached *CachedImage) error {
-	cacheKey := strings.ToLower(strings.TrimSpace(cached.ArtistName))
 
-	_, err := s.db.Exec(`
-		INSERT OR REPLACE INTO artist_images (artist_name_lower, artist_name, image_key, url, source, fetched_at)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`, cacheKey, cached.ArtistName, cached.ImageKey, cached.URL, cached.Source, cached.FetchedAt.Unix())
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})
 
-	return err
+	slog.Info("using redis cache with sqlite as durable fallback", "redis_addr", cfg.RedisAddr)
+	return NewRedisCache(redisClient, sqliteCache, cfg.PositiveCacheTTL)
 }
 
-/// scrapeDeezerImage scrapes artist image from Deezer
-func (s *ArtistImageService) scrapeDeezerImage(artistName string) (string, error) {
-	//URL encode artist name for search
-	encodedName := strings.ReplaceAll(artistName, " ", "%20")
-	deezerSearchURL := fmt.Sprintf("https://www.deezer.com/en/search/%s/artist", encodedName)
-
-	log.Printf("Scraping Deezer: %s\n", deezerSearchURL)
-
-	//Make HTTP request with timeout
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	req, err := http.NewRequest("GET", deezerSearchURL, nil)
-	if err != nil {
-		return "", err
-	}
-
-	//Set basic browser headers
-	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/115.0")
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("failed to fetch Deezer page: status %d", resp.StatusCode)
-	}
-
-	//Read the page content
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-	bodyString := string(bodyBytes)
-
-	//Extract JSON from window.__DZR_APP_STATE__
-	//Look for pattern: window.__DZR_APP_STATE__ = {...}
-	startMarker := "window.__DZR_APP_STATE__ = "
-	startIdx := strings.Index(bodyString, startMarker)
-	if startIdx == -1 {
-		return "", fmt.Errorf("could not find __DZR_APP_STATE__ in page")
-	}
-
-	//Find the JSON object (starts after the marker => ends at first </script>)
-	jsonStart := startIdx + len(startMarker)
-	jsonEnd := strings.Index(bodyString[jsonStart:], "</script>")
-	if jsonEnd == -1 {
-		return "", fmt.Errorf("could not find end of JSON data")
-	}
-
-	jsonString := strings.TrimSpace(bodyString[jsonStart : jsonStart+jsonEnd])
-	//Remove trailing semicolon if present
-	jsonString = strings.TrimSuffix(jsonString, ";")
-
-	//Parse JSON to extract artist image hash
-	var deezerData map[string]interface{}
-	if err := json.Unmarshal([]byte(jsonString), &deezerData); err != nil {
-		return "", fmt.Errorf("failed to parse Deezer JSON: %w", err)
-	}
-
-	//Navigate to ARTIST => data => first artist => ART_PICTURE
-	artistSection, ok := deezerData["ARTIST"].(map[string]interface{})
-	if !ok {
-		return "", fmt.Errorf("no ARTIST section in Deezer data")
-	}
-
-	data, ok := artistSection["data"].([]interface{})
-	if !ok || len(data) == 0 {
-		return "", fmt.Errorf("no artist results found")
-	}
-
-	firstArtist, ok := data[0].(map[string]interface{})
-	if !ok {
-		return "", fmt.Errorf("invalid artist data format")
+//providerNames renders the configured provider chain for a single log line
+func providerNames(providers []ImageProvider) string {
+	names := make([]string, len(providers))
+	for i, p := range providers {
+		names[i] = p.Name()
 	}
+	return strings.Join(names, " -> ")
+}
 
-	artPicture, ok := firstArtist["ART_PICTURE"].(string)
-	if !ok || artPicture == "" {
-		return "", fmt.Errorf("no artist picture hash found")
+//providerTimeoutSum returns the worst-case cumulative time fetchFromProviders could
+//spend walking the full provider chain before giving up on every provider
+func providerTimeoutSum(providers []ImageProvider) time.Duration {
+	var total time.Duration
+	for _, p := range providers {
+		total += p.Timeout()
 	}
+	return total
+}
 
-	//Build CDN URL
-	imageURL := fmt.Sprintf("https://cdn-images.dzcdn.net/images/artist/%s/1000x1000-000000-80-0-0.jpg", artPicture)
-
-	log.Printf("Found Deezer image: %s\n", imageURL)
-	return imageURL, nil
+/// Close closes the database connection
+func (s *ArtistImageService) Close() error {
+	return s.db.Close()
 }
 
-/// uploadImageToMinio downloads and uploads image to MinIO
-func (s *ArtistImageService) uploadImageToMinio(imageURL, artistName string) (string, error) {
+/// uploadImage downloads the source image and stores it in the configured object store
+func (s *ArtistImageService) uploadImage(imageURL, artistName string) (imageKey string, err error) {
+	start := time.Now()
+	ctx, span := startSpan(context.Background(), "objectstore.Put")
+	defer func() {
+		uploadDuration.Observe(time.Since(start).Seconds())
+		endSpan(span, err)
+	}()
+
 	//Download image
 	resp, err := http.Get(imageURL)
 	if err != nil {
@@ -298,83 +236,224 @@ func (s *ArtistImageService) uploadImageToMinio(imageURL, artistName string) (st
 	//Create safe filename (use timestamp to ensure uniqueness)
 	safeArtistName := strings.ReplaceAll(artistName, "/", "_")
 	safeArtistName = strings.ReplaceAll(safeArtistName, "\\", "_")
-	imageKey := fmt.Sprintf("%s_%d%s", safeArtistName, time.Now().Unix(), ext)
+	imageKey = fmt.Sprintf("%s_%d%s", safeArtistName, time.Now().Unix(), ext)
 
-	//Upload to MinIO
-	ctx := context.Background()
-	_, err = s.minioClient.PutObject(ctx, s.bucket, imageKey, bytes.NewReader(imageData), int64(len(imageData)), minio.PutObjectOptions{
-		ContentType: contentType,
-	})
-	if err != nil {
-		return "", fmt.Errorf("failed to upload to MinIO: %w", err)
+	//Store under the photo/ prefix so the preview pipeline can find the original
+	if err = s.store.Put(ctx, photoPrefix+imageKey, bytes.NewReader(imageData), int64(len(imageData)), contentType); err != nil {
+		return "", fmt.Errorf("failed to upload to object store: %w", err)
 	}
 
-	log.Printf("Uploaded image to MinIO: %s\n", imageKey)
+	slog.Info("uploaded image to object store", "image_key", imageKey)
 	return imageKey, nil
 }
 
-/// getMinioURL generates the public URL for a MinIO object
-func (s *ArtistImageService) getMinioURL(imageKey string) string {
-	endpoint := os.Getenv("MINIO_PUBLIC_ENDPOINT")
+/// publicImageURL returns the store-provided public URL for an uploaded original
+func (s *ArtistImageService) publicImageURL(imageKey string) string {
+	return s.store.PublicURL(photoPrefix + imageKey)
+}
 
-	useSSL := os.Getenv("MINIO_USE_SSL") == "true"
-	protocol := "http"
-	if useSSL {
-		protocol = "https"
-	}
+/// GetArtistImage fetches or retrieves cached artist image, trying each configured
+/// provider in order until one returns an image meeting its quality threshold.
+/// Concurrent requests for the same artist within this process always collapse into
+/// a single upstream fetch via singleflight; when the cache also supports distributed
+/// locking, that collapse extends across replicas too
+func (s *ArtistImageService) GetArtistImage(ctx context.Context, artistName string) (cached *CachedImage, err error) {
+	ctx, span := startSpan(ctx, "GetArtistImage", attribute.String("artist_name", artistName))
+	defer func() { endSpan(span, err) }()
 
-	return fmt.Sprintf("%s://%s/%s/%s", protocol, endpoint, s.bucket, imageKey)
-}
+	inFlightFetches.Inc()
+	defer inFlightFetches.Dec()
 
-/// GetArtistImage fetches or retrieves cached artist image
-func (s *ArtistImageService) GetArtistImage(artistName string) (*CachedImage, error) {
-	//Check cache first
-	cached, err := s.getCachedImage(artistName)
+	if cached := s.freshCachedImage(ctx, artistName); cached != nil {
+		recordCacheResult(cached.Source, "hit")
+		return cached, nil
+	}
+	if s.isNegativelyCached(ctx, artistName) {
+		recordCacheResult("none", "negative")
+		return nil, fmt.Errorf("no artist results found")
+	}
+	recordCacheResult("none", "miss")
+
+	//Coalesce concurrent requests for the same artist within this process into a
+	//single upstream resolution; the Locker below still coordinates across replicas
+	sfKey := strings.ToLower(strings.TrimSpace(artistName))
+	v, err, _ := s.fetchGroup.Do(sfKey, func() (interface{}, error) {
+		return s.resolveArtistImage(ctx, artistName)
+	})
 	if err != nil {
-		log.Printf("Warning: failed to check cache: %v\n", err)
+		return nil, err
 	}
+	return v.(*CachedImage), nil
+}
 
-	//If cached and fresh => return it
-	if cached != nil && time.Since(cached.FetchedAt) < 7*24*time.Hour {
-		log.Printf("Returning cached image for: %s\n", artistName)
-		return cached, nil
+//resolveArtistImage performs the actual cache-miss work for an artist: coordinating
+//with other replicas via the distributed lock (when the cache supports one), walking
+//the provider chain, uploading the result, and writing it back to the cache. Calls
+//for the same artist within this process are already coalesced by GetArtistImage's
+//singleflight group before reaching here
+func (s *ArtistImageService) resolveArtistImage(ctx context.Context, artistName string) (*CachedImage, error) {
+	locker, hasLock := s.cache.(Locker)
+	if hasLock {
+		token, acquired, lockErr := locker.AcquireLock(ctx, artistName, s.lockTTL)
+		if lockErr != nil {
+			slog.Warn("failed to acquire distributed lock", "artist_name", artistName, "error", lockErr)
+		} else if !acquired {
+			slog.Info("another replica is already fetching, waiting on cache", "artist_name", artistName)
+			return waitForCache(ctx, s.cache, artistName, s.positiveCacheTTL, s.lockWaitTimeout)
+		} else {
+			defer locker.ReleaseLock(ctx, artistName, token)
+		}
+
+		//Another replica may have populated the cache while we waited for the lock
+		if cached := s.freshCachedImage(ctx, artistName); cached != nil {
+			return cached, nil
+		}
 	}
 
-	//Not in cache or stale => fetch new image
-	log.Printf("Fetching new image for: %s\n", artistName)
+	//Not in cache or stale => walk the provider chain
+	slog.Info("fetching new image", "artist_name", artistName)
 
-	imageURL, err := s.scrapeDeezerImage(artistName)
+	inFlightFetches.Inc()
+	imageURL, providerName, genuineNotFound, err := s.fetchFromProviders(ctx, artistName)
+	inFlightFetches.Dec()
 	if err != nil {
-		return nil, fmt.Errorf("failed to scrape image: %w", err)
+		if genuineNotFound {
+			s.saveNegativeResult(ctx, artistName)
+		} else {
+			slog.Warn("provider chain failed without a genuine not-found answer, skipping negative cache", "artist_name", artistName, "error", err)
+		}
+		return nil, fmt.Errorf("no provider could find an image: %w", err)
 	}
 
-	imageKey, err := s.uploadImageToMinio(imageURL, artistName)
+	imageKey, err := s.uploadImage(imageURL, artistName)
 	if err != nil {
 		//If upload fails => still cache the original URL
-		log.Printf("Warning: failed to upload to MinIO, caching original URL: %v\n", err)
+		slog.Warn("failed to upload to object store, caching original URL", "artist_name", artistName, "error", err)
 		imageKey = ""
+		err = nil
+	} else {
+		s.PrewarmCommonSizes(imageKey)
 	}
 
 	finalURL := imageURL
 	if imageKey != "" {
-		finalURL = s.getMinioURL(imageKey)
+		finalURL = s.publicImageURL(imageKey)
 	}
 
 	//Cache the result
-	cached = &CachedImage{
+	result := &CachedImage{
 		ArtistName: artistName,
 		ImageKey:   imageKey,
 		URL:        finalURL,
-		Source:     "deezer",
+		Source:     providerName,
 		FetchedAt:  time.Now(),
 	}
 
-	err = s.saveCachedImage(cached)
+	if setErr := s.cache.Set(ctx, result); setErr != nil {
+		slog.Warn("failed to save cache entry", "artist_name", artistName, "error", setErr)
+	}
+
+	return result, nil
+}
+
+//freshCachedImage returns the cached positive entry for an artist if one exists and
+//hasn't exceeded the positive cache TTL
+func (s *ArtistImageService) freshCachedImage(ctx context.Context, artistName string) *CachedImage {
+	cached, err := s.cache.Get(ctx, artistName)
+	if err != nil {
+		slog.Warn("failed to check cache", "artist_name", artistName, "error", err)
+		return nil
+	}
+	if cached == nil || cached.Negative || time.Since(cached.FetchedAt) >= s.positiveCacheTTL {
+		return nil
+	}
+
+	slog.Info("returning cached image", "artist_name", artistName)
+	return cached
+}
+
+//isNegativelyCached reports whether a recent negative lookup is still within its TTL
+func (s *ArtistImageService) isNegativelyCached(ctx context.Context, artistName string) bool {
+	cached, err := s.cache.Get(ctx, artistName)
+	if err != nil || cached == nil || !cached.Negative {
+		return false
+	}
+	return time.Since(cached.FetchedAt) < s.negativeCacheTTL
+}
+
+/// fetchFromProviders tries each provider in chain order, skipping results that
+/// fall below that provider's configured quality threshold. The returned bool
+/// reports whether every provider in the chain gave a genuine "artist not found"
+/// answer (ErrArtistNotFound), as opposed to the chain being cut short by a
+/// transient failure (timeout, bad credentials, 5xx) -- only the former is safe
+/// to negative-cache, since the latter would self-amplify an upstream outage into
+/// negative-caching every artist queried during it
+func (s *ArtistImageService) fetchFromProviders(ctx context.Context, artistName string) (string, string, bool, error) {
+	var lastErr error
+	allNotFound := true
+
+	for _, provider := range s.providers {
+		imageURL, meta, err := s.fetchFromProvider(ctx, provider, artistName)
+		if err != nil {
+			lastErr = err
+			if !errors.Is(err, ErrArtistNotFound) {
+				allNotFound = false
+			}
+			continue
+		}
+
+		if meta.quality() < provider.MinQualityThreshold() {
+			slog.Info("provider result below quality threshold, trying next provider", "provider", provider.Name(), "artist_name", artistName)
+			allNotFound = false
+			continue
+		}
+
+		slog.Info("found image via provider", "provider", provider.Name(), "image_url", imageURL)
+		return imageURL, provider.Name(), false, nil
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no providers configured")
+		allNotFound = false
+	}
+	return "", "", allNotFound, lastErr
+}
+
+//fetchFromProvider wraps a single provider's Fetch call with a span and latency
+//histogram observation
+func (s *ArtistImageService) fetchFromProvider(ctx context.Context, provider ImageProvider, artistName string) (imageURL string, meta ProviderMeta, err error) {
+	fetchCtx, cancel := context.WithTimeout(ctx, provider.Timeout())
+	defer cancel()
+
+	fetchCtx, span := startSpan(fetchCtx, "provider.Fetch", attribute.String("provider", provider.Name()))
+	start := time.Now()
+	defer func() {
+		providerFetchDuration.WithLabelValues(provider.Name()).Observe(time.Since(start).Seconds())
+		endSpan(span, err)
+	}()
+
+	if err = s.rateLimiter.WaitForProvider(fetchCtx, provider.Name()); err != nil {
+		return "", nil, fmt.Errorf("rate limit wait for %s: %w", provider.Name(), err)
+	}
+
+	imageURL, meta, err = provider.Fetch(fetchCtx, artistName)
 	if err != nil {
-		log.Printf("Warning: failed to save cache: %v\n", err)
+		slog.Warn("provider failed", "provider", provider.Name(), "artist_name", artistName, "error", err)
 	}
+	return imageURL, meta, err
+}
 
-	return cached, nil
+/// saveNegativeResult records a short-TTL cache entry for an artist no provider could find
+func (s *ArtistImageService) saveNegativeResult(ctx context.Context, artistName string) {
+	err := s.cache.Set(ctx, &CachedImage{
+		ArtistName: artistName,
+		Source:     "none",
+		FetchedAt:  time.Now(),
+		Negative:   true,
+	})
+	if err != nil {
+		slog.Warn("failed to save negative cache entry", "artist_name", artistName, "error", err)
+	}
 }
 
 /// HTTP Handlers
@@ -388,7 +467,7 @@ func (s *ArtistImageService) handleGetArtistImage(w http.ResponseWriter, r *http
 		return
 	}
 
-	cached, err := s.GetArtistImage(artistName)
+	cached, err := s.GetArtistImage(r.Context(), artistName)
 	if err != nil {
 		s.sendJSONResponse(w, http.StatusNotFound, APIResponse{
 			Success:    false,
@@ -414,28 +493,52 @@ func (s *ArtistImageService) handleServeImage(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	cached, err := s.GetArtistImage(artistName)
+	cached, err := s.GetArtistImage(r.Context(), artistName)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
-	//Redirect to the MinIO URL or original URL
-	http.Redirect(w, r, cached.URL, http.StatusFound)
+	//With no variant requested, preserve the original redirect behavior
+	opts, wantsVariant := parsePreviewOptions(r)
+	if !wantsVariant || cached.ImageKey == "" {
+		http.Redirect(w, r, cached.URL, http.StatusFound)
+		return
+	}
+
+	variant, contentType, err := s.GetOrCreatePreview(r.Context(), cached.ImageKey, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer variant.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	if _, err := io.Copy(w, variant); err != nil {
+		slog.Warn("failed to stream variant", "artist_name", artistName, "error", err)
+	}
 }
 
 func (s *ArtistImageService) handleStats(w http.ResponseWriter, r *http.Request) {
-	count, err := s.getCacheCount()
+	count, err := s.cache.Count(r.Context())
 	if err != nil {
-		log.Printf("Error getting cache count: %v\n", err)
+		slog.Error("failed to get cache count", "error", err)
 		count = 0
 	}
+	bucketObjectsGauge.Set(float64(count))
+
+	negativeCount, err := s.cache.NegativeCount(r.Context())
+	if err != nil {
+		slog.Error("failed to get negative cache count", "error", err)
+		negativeCount = 0
+	}
 
 	stats := map[string]interface{}{
-		"cached_artists": count,
-		"bucket":         s.bucket,
-		"storage":        "minio",
-		"database":       "sqlite",
+		"cached_artists":          count,
+		"negative_cached_artists": negativeCount,
+		"bucket":                  s.bucketName,
+		"storage":                 s.storageBackend,
+		"database":                "sqlite",
 	}
 
 	s.sendJSONResponse(w, http.StatusOK, stats)
@@ -448,18 +551,28 @@ func (s *ArtistImageService) sendJSONResponse(w http.ResponseWriter, status int,
 }
 
 func main() {
+	initLogging()
+
 	//Load environment variables
 	godotenv.Load()
 	port := os.Getenv("PORT")
 	dbPath := os.Getenv("DB_PATH")
-	minioEndpoint := os.Getenv("MINIO_ENDPOINT")
-	minioAccessKey := os.Getenv("MINIO_ACCESS_KEY")
-	minioSecretKey := os.Getenv("MINIO_SECRET_KEY")
-	minioBucket := os.Getenv("MINIO_BUCKET")
-	minioUseSSL := os.Getenv("MINIO_USE_SSL") == "true"
+	configPath := os.Getenv("CONFIG_PATH")
+
+	//Load provider chain and storage backend configuration
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v\n", err)
+	}
+
+	ctx := context.Background()
+	store, err := NewObjectStore(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize object store: %v\n", err)
+	}
 
 	//Create service
-	service, err := NewArtistImageService(dbPath, minioEndpoint, minioAccessKey, minioSecretKey, minioBucket, minioUseSSL)
+	service, err := NewArtistImageService(dbPath, store, cfg)
 	if err != nil {
 		log.Fatalf("Failed to create service: %v\n", err)
 	}
@@ -469,9 +582,13 @@ func main() {
 	router := mux.NewRouter()
 
 	//API endpoints
-	router.HandleFunc("/api/artist-image", service.handleGetArtistImage).Methods("GET")
-	router.HandleFunc("/api/artist-image/serve", service.handleServeImage).Methods("GET")
-	router.HandleFunc("/api/stats", service.handleStats).Methods("GET")
+	router.HandleFunc("/api/artist-image", tracingMiddleware("artist_image", rateLimitMiddleware(service.rateLimiter, service.handleGetArtistImage))).Methods("GET")
+	router.HandleFunc("/api/artist-image/serve", tracingMiddleware("artist_image_serve", rateLimitMiddleware(service.rateLimiter, service.handleServeImage))).Methods("GET")
+	router.HandleFunc("/api/artist-image/upload", tracingMiddleware("artist_image_upload", rateLimitMiddleware(service.rateLimiter, service.handleUploadArtistImage))).Methods("POST", "PATCH")
+	router.HandleFunc("/api/stats", tracingMiddleware("stats", service.handleStats)).Methods("GET")
+
+	//Observability endpoints
+	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
 
 	//Health check
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -483,8 +600,9 @@ func main() {
 	router.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Upload-Length, Upload-Offset, Upload-Id")
+			w.Header().Set("Access-Control-Expose-Headers", "Upload-Id, Upload-Offset")
 
 			if r.Method == "OPTIONS" {
 				w.WriteHeader(http.StatusOK)
@@ -496,8 +614,6 @@ func main() {
 	})
 
 	//Start server
-	log.Printf("Starting Artist Image Service on port %s\n", port)
-	log.Printf("Database: %s\n", dbPath)
-	log.Printf("MinIO: %s (bucket: %s)\n", minioEndpoint, minioBucket)
+	slog.Info("starting artist image service", "port", port, "database", dbPath)
 	log.Fatal(http.ListenAndServe(":"+port, router))
 }
\ No newline at end of file
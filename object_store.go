@@ -0,0 +1,480 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	cos "github.com/tencentyun/cos-go-sdk-v5"
+)
+
+/// ErrObjectNotFound is returned by ObjectStore.Stat/Get when the key doesn't exist
+var ErrObjectNotFound = errors.New("object not found")
+
+/// ObjectInfo describes a stored object's size and content type
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+}
+
+/// ObjectStore abstracts the blob storage backend so the service can run against
+/// MinIO, AWS S3, Tencent COS, Aliyun OSS, or a local directory interchangeably
+type ObjectStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	PresignedGet(ctx context.Context, key string, expiry time.Duration) (string, error)
+	PublicURL(key string) string
+}
+
+//bucketPolicy is the public-read bucket policy shared by the S3-compatible backends
+func bucketPolicy(bucket string) string {
+	return fmt.Sprintf(`{
+		"Version": "2012-10-17",
+		"Statement": [{
+			"Effect": "Allow",
+			"Principal": {"AWS": ["*"]},
+			"Action": ["s3:GetObject"],
+			"Resource": ["arn:aws:s3:::%s/*"]
+		}]
+	}`, bucket)
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// MinIO
+//////////////////////////////////////////////////////////////////////////////
+
+/// MinIOStore stores objects in a self-hosted MinIO bucket
+type MinIOStore struct {
+	client         *minio.Client
+	bucket         string
+	publicEndpoint string
+	useSSL         bool
+}
+
+/// NewMinIOStore connects to MinIO, creating the bucket and its public-read policy
+/// if they don't already exist
+func NewMinIOStore(endpoint, accessKey, secretKey, bucket, publicEndpoint string, useSSL bool) (*MinIOStore, error) {
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: useSSL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create MinIO client: %w", err)
+	}
+
+	ctx := context.Background()
+	exists, err := client.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check bucket: %w", err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to create bucket: %w", err)
+		}
+	}
+
+	if err := client.SetBucketPolicy(ctx, bucket, bucketPolicy(bucket)); err != nil {
+		slog.Warn("failed to set MinIO bucket policy", "bucket", bucket, "error", err)
+	}
+
+	if publicEndpoint == "" {
+		publicEndpoint = endpoint
+	}
+
+	return &MinIOStore{client: client, bucket: bucket, publicEndpoint: publicEndpoint, useSSL: useSSL}, nil
+}
+
+func (m *MinIOStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := m.client.PutObject(ctx, m.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (m *MinIOStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	obj, err := m.client.GetObject(ctx, m.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		return nil, translateMinioNotFound(err)
+	}
+	return obj, nil
+}
+
+func (m *MinIOStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	info, err := m.client.StatObject(ctx, m.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return ObjectInfo{}, translateMinioNotFound(err)
+	}
+	return ObjectInfo{Size: info.Size, ContentType: info.ContentType}, nil
+}
+
+func (m *MinIOStore) Delete(ctx context.Context, key string) error {
+	return m.client.RemoveObject(ctx, m.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (m *MinIOStore) PresignedGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	u, err := m.client.PresignedGetObject(ctx, m.bucket, key, expiry, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (m *MinIOStore) PublicURL(key string) string {
+	protocol := "http"
+	if m.useSSL {
+		protocol = "https"
+	}
+	return fmt.Sprintf("%s://%s/%s/%s", protocol, m.publicEndpoint, m.bucket, key)
+}
+
+/// ComposeObject assembles previously uploaded parts into a single object using
+/// MinIO's server-side compose, so multipart uploads never round-trip through this
+/// process
+func (m *MinIOStore) ComposeObject(ctx context.Context, destKey string, partKeys []string, contentType string) error {
+	sources := make([]minio.CopySrcOptions, len(partKeys))
+	for i, key := range partKeys {
+		sources[i] = minio.CopySrcOptions{Bucket: m.bucket, Object: key}
+	}
+
+	dest := minio.CopyDestOptions{Bucket: m.bucket, Object: destKey}
+	_, err := m.client.ComposeObject(ctx, dest, sources...)
+	return err
+}
+
+func translateMinioNotFound(err error) error {
+	resp := minio.ToErrorResponse(err)
+	if resp.Code == "NoSuchKey" {
+		return ErrObjectNotFound
+	}
+	return err
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// AWS S3
+//////////////////////////////////////////////////////////////////////////////
+
+/// S3Store stores objects in an AWS S3 bucket
+type S3Store struct {
+	client        *s3.Client
+	bucket        string
+	region        string
+	publicBaseURL string
+}
+
+/// NewS3Store loads AWS credentials from the environment/shared config and creates
+/// the bucket if it doesn't already exist
+func NewS3Store(ctx context.Context, region, bucket, publicBaseURL string) (*S3Store, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg)
+
+	_, err = client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: &bucket})
+	if err != nil {
+		_, createErr := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: &bucket})
+		if createErr != nil {
+			return nil, fmt.Errorf("failed to create S3 bucket: %w", createErr)
+		}
+	}
+
+	if publicBaseURL == "" {
+		publicBaseURL = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, region)
+	}
+
+	return &S3Store{client: client, bucket: bucket, region: region, publicBaseURL: publicBaseURL}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        &s.bucket,
+		Key:           &key,
+		Body:          r,
+		ContentLength: &size,
+		ContentType:   &contentType,
+	})
+	return err
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{Bucket: &s.bucket, Key: &key})
+	if err != nil {
+		return nil, translateS3NotFound(err)
+	}
+	return out.Body, nil
+}
+
+func (s *S3Store) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &s.bucket, Key: &key})
+	if err != nil {
+		return ObjectInfo{}, translateS3NotFound(err)
+	}
+	info := ObjectInfo{}
+	if out.ContentLength != nil {
+		info.Size = *out.ContentLength
+	}
+	if out.ContentType != nil {
+		info.ContentType = *out.ContentType
+	}
+	return info, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: &s.bucket, Key: &key})
+	return err
+}
+
+func (s *S3Store) PresignedGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	presignClient := s3.NewPresignClient(s.client)
+	req, err := presignClient.PresignGetObject(ctx, &s3.GetObjectInput{Bucket: &s.bucket, Key: &key}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+func (s *S3Store) PublicURL(key string) string {
+	return fmt.Sprintf("%s/%s", s.publicBaseURL, key)
+}
+
+func translateS3NotFound(err error) error {
+	var nf interface{ ErrorCode() string }
+	if errors.As(err, &nf) && (nf.ErrorCode() == "NoSuchKey" || nf.ErrorCode() == "NotFound") {
+		return ErrObjectNotFound
+	}
+	return err
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// Tencent COS
+//////////////////////////////////////////////////////////////////////////////
+
+/// COSStore stores objects in a Tencent Cloud Object Storage bucket
+type COSStore struct {
+	client *cos.Client
+	bucket string
+}
+
+/// NewCOSStore builds a COS client for the given bucket URL (e.g.
+/// https://<bucket>-<appid>.cos.<region>.myqcloud.com) authenticated via secret id/key
+func NewCOSStore(bucketURL, secretID, secretKey string) (*COSStore, error) {
+	u, err := url.Parse(bucketURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid COS bucket URL: %w", err)
+	}
+
+	client := cos.NewClient(&cos.BaseURL{BucketURL: u}, &http.Client{
+		Transport: &cos.AuthorizationTransport{SecretID: secretID, SecretKey: secretKey},
+	})
+
+	return &COSStore{client: client, bucket: bucketURL}, nil
+}
+
+func (c *COSStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := c.client.Object.Put(ctx, key, r, &cos.ObjectPutOptions{
+		ObjectPutHeaderOptions: &cos.ObjectPutHeaderOptions{ContentLength: size, ContentType: contentType},
+	})
+	return err
+}
+
+func (c *COSStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := c.client.Object.Get(ctx, key, nil)
+	if err != nil {
+		if cos.IsNotFoundError(err) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (c *COSStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	resp, err := c.client.Object.Head(ctx, key, nil)
+	if err != nil {
+		if cos.IsNotFoundError(err) {
+			return ObjectInfo{}, ErrObjectNotFound
+		}
+		return ObjectInfo{}, err
+	}
+	size := resp.ContentLength
+	return ObjectInfo{Size: size, ContentType: resp.Header.Get("Content-Type")}, nil
+}
+
+func (c *COSStore) Delete(ctx context.Context, key string) error {
+	_, err := c.client.Object.Delete(ctx, key)
+	return err
+}
+
+func (c *COSStore) PresignedGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	u, err := c.client.Object.GetPresignedURL(ctx, http.MethodGet, key, "", "", expiry, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (c *COSStore) PublicURL(key string) string {
+	return fmt.Sprintf("%s/%s", c.bucket, key)
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// Aliyun OSS
+//////////////////////////////////////////////////////////////////////////////
+
+/// OSSStore stores objects in an Aliyun Object Storage Service bucket
+type OSSStore struct {
+	bucket     *oss.Bucket
+	bucketName string
+	endpoint   string
+}
+
+/// NewOSSStore connects to Aliyun OSS, creating the bucket if it doesn't already exist
+func NewOSSStore(endpoint, accessKeyID, accessKeySecret, bucketName string) (*OSSStore, error) {
+	client, err := oss.New(endpoint, accessKeyID, accessKeySecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OSS client: %w", err)
+	}
+
+	exists, err := client.IsBucketExist(bucketName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check OSS bucket: %w", err)
+	}
+	if !exists {
+		if err := client.CreateBucket(bucketName, oss.ACL(oss.ACLPublicRead)); err != nil {
+			return nil, fmt.Errorf("failed to create OSS bucket: %w", err)
+		}
+	}
+
+	bucket, err := client.Bucket(bucketName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open OSS bucket: %w", err)
+	}
+
+	return &OSSStore{bucket: bucket, bucketName: bucketName, endpoint: endpoint}, nil
+}
+
+func (o *OSSStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	return o.bucket.PutObject(key, r, oss.ContentType(contentType))
+}
+
+func (o *OSSStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	rc, err := o.bucket.GetObject(key)
+	if err != nil {
+		if ossErr, ok := err.(oss.ServiceError); ok && ossErr.StatusCode == 404 {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	return rc, nil
+}
+
+func (o *OSSStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	header, err := o.bucket.GetObjectMeta(key)
+	if err != nil {
+		if ossErr, ok := err.(oss.ServiceError); ok && ossErr.StatusCode == 404 {
+			return ObjectInfo{}, ErrObjectNotFound
+		}
+		return ObjectInfo{}, err
+	}
+	size, _ := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	return ObjectInfo{Size: size, ContentType: header.Get("Content-Type")}, nil
+}
+
+func (o *OSSStore) Delete(ctx context.Context, key string) error {
+	return o.bucket.DeleteObject(key)
+}
+
+func (o *OSSStore) PresignedGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return o.bucket.SignURL(key, oss.HTTPGet, int64(expiry.Seconds()))
+}
+
+func (o *OSSStore) PublicURL(key string) string {
+	return fmt.Sprintf("https://%s.%s/%s", o.bucketName, o.endpoint, key)
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// Local filesystem (dev/testing)
+//////////////////////////////////////////////////////////////////////////////
+
+/// LocalFSStore stores objects as plain files under a base directory, for local
+/// development and tests where no real object storage is available
+type LocalFSStore struct {
+	baseDir       string
+	publicBaseURL string
+}
+
+/// NewLocalFSStore creates the base directory if needed
+func NewLocalFSStore(baseDir, publicBaseURL string) (*LocalFSStore, error) {
+	if err := os.MkdirAll(baseDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create local storage dir: %w", err)
+	}
+	return &LocalFSStore{baseDir: baseDir, publicBaseURL: publicBaseURL}, nil
+}
+
+func (l *LocalFSStore) path(key string) string {
+	return filepath.Join(l.baseDir, filepath.FromSlash(key))
+}
+
+func (l *LocalFSStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	path := l.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	return err
+}
+
+func (l *LocalFSStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(l.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
+	return f, err
+}
+
+func (l *LocalFSStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	info, err := os.Stat(l.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return ObjectInfo{}, ErrObjectNotFound
+	}
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{Size: info.Size()}, nil
+}
+
+func (l *LocalFSStore) Delete(ctx context.Context, key string) error {
+	return os.Remove(l.path(key))
+}
+
+func (l *LocalFSStore) PresignedGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return l.PublicURL(key), nil
+}
+
+func (l *LocalFSStore) PublicURL(key string) string {
+	return fmt.Sprintf("%s/%s", l.publicBaseURL, key)
+}
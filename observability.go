@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//tracer is the service-wide OpenTelemetry tracer. With no SDK configured it's a
+//safe no-op, matching how this service treats Prometheus/slog as optional overlays
+var tracer = otel.Tracer("artist-image-service")
+
+var (
+	cacheResultsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "artist_image_service_cache_results_total",
+		Help: "Cache lookups by provider and result (hit, miss, negative).",
+	}, []string{"provider", "result"})
+
+	providerFetchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "artist_image_service_provider_fetch_duration_seconds",
+		Help:    "Latency of a single provider's Fetch call.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"provider"})
+
+	uploadDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "artist_image_service_upload_duration_seconds",
+		Help:    "Latency of uploading a fetched image to the object store.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	inFlightFetches = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "artist_image_service_in_flight_fetches",
+		Help: "Number of GetArtistImage calls currently walking the provider chain.",
+	})
+
+	//bucketObjectsGauge approximates stored object count from the cache row count,
+	//since ObjectStore has no cheap way to list objects across all backends
+	bucketObjectsGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "artist_image_service_bucket_objects",
+		Help: "Approximate number of objects in the configured bucket, derived from the cache row count.",
+	})
+
+	httpResponsesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "artist_image_service_http_responses_total",
+		Help: "HTTP responses by route and status code.",
+	}, []string{"route", "status"})
+)
+
+//initLogging installs a JSON slog handler as the process-wide default logger
+func initLogging() {
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+}
+
+//statusRecorder wraps http.ResponseWriter to capture the status code for metrics,
+//since the standard library doesn't expose it after the fact
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+//tracingMiddleware extracts any incoming W3C trace context and starts a span for
+//the request, tagging the response status for both the span and the HTTP
+//response counter
+func tracingMiddleware(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, "http."+route, trace.WithAttributes(
+			attribute.String("http.method", r.Method),
+			attribute.String("http.route", route),
+		))
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.status_code", rec.status))
+		if rec.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+		httpResponsesTotal.WithLabelValues(route, strconv.Itoa(rec.status)).Inc()
+	}
+}
+
+//recordCacheResult increments the per-provider, per-outcome cache counter used to
+//track hit/miss/negative rates
+func recordCacheResult(provider, result string) {
+	cacheResultsTotal.WithLabelValues(provider, result).Inc()
+}
+
+//startSpan is a thin wrapper so call sites don't need to import the otel packages
+//directly for the common case of a single named span
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		span.RecordError(err)
+	}
+	span.End()
+}
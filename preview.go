@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/chai2010/webp"
+	"github.com/nfnt/resize"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	photoPrefix         = "photo/"
+	previewPrefix       = "preview/"
+	photoMetadataPrefix = "photometadata/"
+
+	defaultPreviewQuality = 80
+)
+
+//commonPreviewSizes are pre-warmed as soon as a new original is ingested
+var commonPreviewSizes = []PreviewOptions{
+	{Height: 64, Quality: defaultPreviewQuality, Format: "jpeg"},
+	{Height: 128, Quality: defaultPreviewQuality, Format: "jpeg"},
+	{Height: 256, Quality: defaultPreviewQuality, Format: "jpeg"},
+	{Height: 256, Quality: defaultPreviewQuality, Format: "webp"},
+}
+
+/// PreviewOptions describes a single resized/re-encoded variant of an original image
+type PreviewOptions struct {
+	Height  int
+	Quality int
+	Format  string
+}
+
+/// PreviewMetadata is the sidecar JSON stored alongside each generated variant
+type PreviewMetadata struct {
+	Width         int    `json:"width"`
+	Height        int    `json:"height"`
+	SizeBytes     int    `json:"size_bytes"`
+	DominantColor string `json:"dominant_color"`
+	DHash         string `json:"dhash"`
+}
+
+//parsePreviewOptions reads h/q/fmt query params, defaulting quality to 80 and format to jpeg
+func parsePreviewOptions(r *http.Request) (PreviewOptions, bool) {
+	q := r.URL.Query()
+	heightStr := q.Get("h")
+	if heightStr == "" {
+		return PreviewOptions{}, false
+	}
+
+	height, err := strconv.Atoi(heightStr)
+	if err != nil || height <= 0 {
+		height = 0
+	}
+	if height > maxImageDimension {
+		height = maxImageDimension
+	}
+
+	quality := defaultPreviewQuality
+	if qs := q.Get("q"); qs != "" {
+		if v, err := strconv.Atoi(qs); err == nil && v > 0 && v <= 100 {
+			quality = v
+		}
+	}
+
+	format := strings.ToLower(q.Get("fmt"))
+	switch format {
+	case "jpeg", "jpg":
+		format = "jpeg"
+	case "png":
+		format = "png"
+	case "webp":
+		format = "webp"
+	default:
+		format = "jpeg"
+	}
+
+	return PreviewOptions{Height: height, Quality: quality, Format: format}, true
+}
+
+//variantKey builds the preview/<key>_h<height>q<quality>.<format> object name
+func variantKey(imageKey string, opts PreviewOptions) string {
+	ext := opts.Format
+	if ext == "jpeg" {
+		ext = "jpg"
+	}
+	return fmt.Sprintf("%s%s_h%dq%d.%s", previewPrefix, imageKey, opts.Height, opts.Quality, ext)
+}
+
+//metadataKey builds the photometadata/<key>/<variant>.json object name
+func metadataKey(imageKey, variantName string) string {
+	return fmt.Sprintf("%s%s/%s.json", photoMetadataPrefix, imageKey, variantName)
+}
+
+/// GetOrCreatePreview returns an io.ReadCloser and content-type for the requested
+/// variant, generating and caching it in the object store on first request
+func (s *ArtistImageService) GetOrCreatePreview(ctx context.Context, imageKey string, opts PreviewOptions) (io.ReadCloser, string, error) {
+	vKey := variantKey(imageKey, opts)
+
+	if _, err := s.store.Stat(ctx, vKey); err == nil {
+		if obj, err := s.store.Get(ctx, vKey); err == nil {
+			return obj, contentTypeForFormat(opts.Format), nil
+		}
+	}
+
+	return s.generatePreview(ctx, imageKey, opts)
+}
+
+//generatePreview downloads the original, resizes/re-encodes it, and stores the
+//variant plus its metadata sidecar in the object store
+func (s *ArtistImageService) generatePreview(ctx context.Context, imageKey string, opts PreviewOptions) (io.ReadCloser, string, error) {
+	originalKey := photoPrefix + imageKey
+
+	obj, err := s.store.Get(ctx, originalKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch original: %w", err)
+	}
+	defer obj.Close()
+
+	img, _, err := image.Decode(obj)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode original image: %w", err)
+	}
+
+	resized := img
+	if opts.Height > 0 {
+		resized = resize.Resize(0, uint(opts.Height), img, resize.Lanczos3)
+	}
+
+	var buf bytes.Buffer
+	switch opts.Format {
+	case "png":
+		err = png.Encode(&buf, resized)
+	case "webp":
+		err = webp.Encode(&buf, resized, &webp.Options{Quality: float32(opts.Quality)})
+	default:
+		err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: opts.Quality})
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode %s variant: %w", opts.Format, err)
+	}
+
+	vKey := variantKey(imageKey, opts)
+	if err := s.store.Put(ctx, vKey, bytes.NewReader(buf.Bytes()), int64(buf.Len()), contentTypeForFormat(opts.Format)); err != nil {
+		return nil, "", fmt.Errorf("failed to store variant: %w", err)
+	}
+
+	s.saveVariantMetadata(ctx, imageKey, vKey, resized, buf.Len())
+
+	return io.NopCloser(bytes.NewReader(buf.Bytes())), contentTypeForFormat(opts.Format), nil
+}
+
+//saveVariantMetadata computes and stores the dominant color + dhash sidecar for a variant
+func (s *ArtistImageService) saveVariantMetadata(ctx context.Context, imageKey, vKey string, img image.Image, sizeBytes int) {
+	bounds := img.Bounds()
+	meta := PreviewMetadata{
+		Width:         bounds.Dx(),
+		Height:        bounds.Dy(),
+		SizeBytes:     sizeBytes,
+		DominantColor: dominantColor(img),
+		DHash:         dhash(img),
+	}
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		slog.Warn("failed to marshal preview metadata", "variant_key", vKey, "error", err)
+		return
+	}
+
+	variantName := strings.TrimPrefix(vKey, previewPrefix)
+	mKey := metadataKey(imageKey, variantName)
+	if err := s.store.Put(ctx, mKey, bytes.NewReader(data), int64(len(data)), "application/json"); err != nil {
+		slog.Warn("failed to store preview metadata", "variant_key", vKey, "error", err)
+	}
+}
+
+//PrewarmCommonSizes generates the standard set of variants for a freshly ingested
+//original in the background so the first real request never pays the resize cost
+func (s *ArtistImageService) PrewarmCommonSizes(imageKey string) {
+	if imageKey == "" {
+		return
+	}
+
+	go func() {
+		ctx := context.Background()
+		for _, opts := range commonPreviewSizes {
+			if _, _, err := s.generatePreview(ctx, imageKey, opts); err != nil {
+				slog.Warn("failed to pre-warm variant", "image_key", imageKey, "height", opts.Height, "quality", opts.Quality, "format", opts.Format, "error", err)
+			}
+		}
+	}()
+}
+
+//contentTypeForFormat maps an output format to its HTTP content type
+func contentTypeForFormat(format string) string {
+	switch format {
+	case "png":
+		return "image/png"
+	case "webp":
+		return "image/webp"
+	default:
+		return "image/jpeg"
+	}
+}
+
+//dominantColor returns the average color of the image as a "#rrggbb" hex string
+func dominantColor(img image.Image) string {
+	bounds := img.Bounds()
+	var rSum, gSum, bSum, count uint64
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += maxInt(1, bounds.Dy()/32) {
+		for x := bounds.Min.X; x < bounds.Max.X; x += maxInt(1, bounds.Dx()/32) {
+			r, g, b, _ := img.At(x, y).RGBA()
+			rSum += uint64(r >> 8)
+			gSum += uint64(g >> 8)
+			bSum += uint64(b >> 8)
+			count++
+		}
+	}
+
+	if count == 0 {
+		return "#000000"
+	}
+	return fmt.Sprintf("#%02x%02x%02x", rSum/count, gSum/count, bSum/count)
+}
+
+//dhash computes a difference hash (8x8 grayscale gradient) of the image, returned as hex
+func dhash(img image.Image) string {
+	small := resize.Resize(9, 8, img, resize.Bilinear)
+	bounds := small.Bounds()
+
+	var hash uint64
+	var bit uint
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X-1; x++ {
+			left := grayscale(small.At(x, y))
+			right := grayscale(small.At(x+1, y))
+			if left < right {
+				hash |= 1 << bit
+			}
+			bit++
+		}
+	}
+
+	return fmt.Sprintf("%016x", hash)
+}
+
+//grayscale converts a color to a single luminance value
+func grayscale(c color.Color) uint8 {
+	gray := color.GrayModel.Convert(c).(color.Gray)
+	return gray.Y
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/// ErrArtistNotFound is returned by a provider's Fetch when it reached upstream and
+/// got a definitive "this artist has no image" answer, as opposed to a transient
+/// failure (timeout, bad credentials, 5xx). Only a chain where every provider returns
+/// this error is a genuine miss worth negative-caching
+var ErrArtistNotFound = errors.New("no artist results found")
+
+/// ProviderMeta carries provider-specific details about a fetched image (dimensions,
+/// a quality score, upstream ids) alongside the URL
+type ProviderMeta map[string]string
+
+/// ImageProvider is a single upstream source of artist images. Implementations must
+/// respect the passed context for cancellation/timeouts
+type ImageProvider interface {
+	Name() string
+	Fetch(ctx context.Context, artistName string) (imageURL string, meta ProviderMeta, err error)
+	Timeout() time.Duration
+	MinQualityThreshold() int
+}
+
+/// quality returns the provider's self-reported quality score, defaulting to 0
+func (m ProviderMeta) quality() int {
+	q, _ := strconv.Atoi(m["quality"])
+	return q
+}
+
+/// DeezerProvider scrapes the Deezer search page for an artist's picture hash
+type DeezerProvider struct {
+	FetchTimeout time.Duration
+	MinQuality   int
+}
+
+func (p *DeezerProvider) Name() string { return "deezer" }
+func (p *DeezerProvider) Timeout() time.Duration { return p.FetchTimeout }
+func (p *DeezerProvider) MinQualityThreshold() int { return p.MinQuality }
+
+func (p *DeezerProvider) Fetch(ctx context.Context, artistName string) (string, ProviderMeta, error) {
+	encodedName := strings.ReplaceAll(artistName, " ", "%20")
+	deezerSearchURL := fmt.Sprintf("https://www.deezer.com/en/search/%s/artist", encodedName)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", deezerSearchURL, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/115.0")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
+
+	client := &http.Client{Timeout: p.FetchTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", nil, fmt.Errorf("failed to fetch Deezer page: status %d", resp.StatusCode)
+	}
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, err
+	}
+	bodyString := string(bodyBytes)
+
+	//Extract JSON from window.__DZR_APP_STATE__
+	startMarker := "window.__DZR_APP_STATE__ = "
+	startIdx := strings.Index(bodyString, startMarker)
+	if startIdx == -1 {
+		return "", nil, fmt.Errorf("could not find __DZR_APP_STATE__ in page")
+	}
+
+	jsonStart := startIdx + len(startMarker)
+	jsonEnd := strings.Index(bodyString[jsonStart:], "</script>")
+	if jsonEnd == -1 {
+		return "", nil, fmt.Errorf("could not find end of JSON data")
+	}
+
+	jsonString := strings.TrimSpace(bodyString[jsonStart : jsonStart+jsonEnd])
+	jsonString = strings.TrimSuffix(jsonString, ";")
+
+	var deezerData map[string]interface{}
+	if err := json.Unmarshal([]byte(jsonString), &deezerData); err != nil {
+		return "", nil, fmt.Errorf("failed to parse Deezer JSON: %w", err)
+	}
+
+	artistSection, ok := deezerData["ARTIST"].(map[string]interface{})
+	if !ok {
+		return "", nil, fmt.Errorf("no ARTIST section in Deezer data")
+	}
+
+	data, ok := artistSection["data"].([]interface{})
+	if !ok || len(data) == 0 {
+		return "", nil, ErrArtistNotFound
+	}
+
+	firstArtist, ok := data[0].(map[string]interface{})
+	if !ok {
+		return "", nil, fmt.Errorf("invalid artist data format")
+	}
+
+	artPicture, ok := firstArtist["ART_PICTURE"].(string)
+	if !ok || artPicture == "" {
+		return "", nil, fmt.Errorf("no artist picture hash found")
+	}
+
+	imageURL := fmt.Sprintf("https://cdn-images.dzcdn.net/images/artist/%s/1000x1000-000000-80-0-0.jpg", artPicture)
+	meta := ProviderMeta{"quality": "80", "width": "1000", "height": "1000"}
+	return imageURL, meta, nil
+}
+
+/// SpotifyProvider uses the Spotify Web API client-credentials flow to search for
+/// an artist and pick the largest returned image
+type SpotifyProvider struct {
+	ClientID     string
+	ClientSecret string
+	FetchTimeout time.Duration
+	MinQuality   int
+}
+
+func (p *SpotifyProvider) Name() string { return "spotify" }
+func (p *SpotifyProvider) Timeout() time.Duration { return p.FetchTimeout }
+func (p *SpotifyProvider) MinQualityThreshold() int { return p.MinQuality }
+
+func (p *SpotifyProvider) token(ctx context.Context) (string, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://accounts.spotify.com/api/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(p.ClientID, p.ClientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := &http.Client{Timeout: p.FetchTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("spotify token request failed: status %d", resp.StatusCode)
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", err
+	}
+	return tokenResp.AccessToken, nil
+}
+
+func (p *SpotifyProvider) Fetch(ctx context.Context, artistName string) (string, ProviderMeta, error) {
+	token, err := p.token(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get Spotify token: %w", err)
+	}
+
+	searchURL := fmt.Sprintf("https://api.spotify.com/v1/search?q=%s&type=artist&limit=1", url.QueryEscape(artistName))
+	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	client := &http.Client{Timeout: p.FetchTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", nil, fmt.Errorf("spotify search failed: status %d", resp.StatusCode)
+	}
+
+	var searchResp struct {
+		Artists struct {
+			Items []struct {
+				Images []struct {
+					URL    string `json:"url"`
+					Width  int    `json:"width"`
+					Height int    `json:"height"`
+				} `json:"images"`
+			} `json:"items"`
+		} `json:"artists"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&searchResp); err != nil {
+		return "", nil, err
+	}
+
+	if len(searchResp.Artists.Items) == 0 || len(searchResp.Artists.Items[0].Images) == 0 {
+		return "", nil, ErrArtistNotFound
+	}
+
+	//Images are returned widest-first by Spotify
+	best := searchResp.Artists.Items[0].Images[0]
+	meta := ProviderMeta{
+		"quality": strconv.Itoa(best.Width),
+		"width":   strconv.Itoa(best.Width),
+		"height":  strconv.Itoa(best.Height),
+	}
+	return best.URL, meta, nil
+}
+
+/// MusicBrainzProvider resolves an artist MBID via the MusicBrainz search API, then
+/// looks up front cover art for that artist's release group via the Cover Art Archive
+type MusicBrainzProvider struct {
+	UserAgent  string
+	FetchTimeout time.Duration
+	MinQuality   int
+}
+
+func (p *MusicBrainzProvider) Name() string { return "musicbrainz" }
+func (p *MusicBrainzProvider) Timeout() time.Duration { return p.FetchTimeout }
+func (p *MusicBrainzProvider) MinQualityThreshold() int { return p.MinQuality }
+
+func (p *MusicBrainzProvider) Fetch(ctx context.Context, artistName string) (string, ProviderMeta, error) {
+	client := &http.Client{Timeout: p.FetchTimeout}
+
+	searchURL := fmt.Sprintf("https://musicbrainz.org/ws/2/artist/?query=%s&fmt=json&limit=1", url.QueryEscape(artistName))
+	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header.Set("User-Agent", p.UserAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", nil, fmt.Errorf("musicbrainz artist search failed: status %d", resp.StatusCode)
+	}
+
+	var artistResp struct {
+		Artists []struct {
+			ID string `json:"id"`
+		} `json:"artists"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&artistResp); err != nil {
+		return "", nil, err
+	}
+	if len(artistResp.Artists) == 0 {
+		return "", nil, ErrArtistNotFound
+	}
+	mbid := artistResp.Artists[0].ID
+
+	releaseGroupURL := fmt.Sprintf("https://musicbrainz.org/ws/2/release-group/?artist=%s&fmt=json&limit=1", mbid)
+	req, err = http.NewRequestWithContext(ctx, "GET", releaseGroupURL, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header.Set("User-Agent", p.UserAgent)
+
+	resp, err = client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", nil, fmt.Errorf("musicbrainz release-group lookup failed: status %d", resp.StatusCode)
+	}
+
+	var rgResp struct {
+		ReleaseGroups []struct {
+			ID string `json:"id"`
+		} `json:"release-groups"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rgResp); err != nil {
+		return "", nil, err
+	}
+	if len(rgResp.ReleaseGroups) == 0 {
+		return "", nil, ErrArtistNotFound
+	}
+
+	coverArtURL := fmt.Sprintf("https://coverartarchive.org/release-group/%s/front", rgResp.ReleaseGroups[0].ID)
+	meta := ProviderMeta{"quality": "60", "mbid": mbid}
+	return coverArtURL, meta, nil
+}
+
+/// LastFMProvider queries the Last.fm artist.getinfo API for the artist's largest image
+type LastFMProvider struct {
+	APIKey     string
+	FetchTimeout time.Duration
+	MinQuality   int
+}
+
+func (p *LastFMProvider) Name() string { return "lastfm" }
+func (p *LastFMProvider) Timeout() time.Duration { return p.FetchTimeout }
+func (p *LastFMProvider) MinQualityThreshold() int { return p.MinQuality }
+
+func (p *LastFMProvider) Fetch(ctx context.Context, artistName string) (string, ProviderMeta, error) {
+	apiURL := fmt.Sprintf("https://ws.audioscrobbler.com/2.0/?method=artist.getinfo&artist=%s&api_key=%s&format=json",
+		url.QueryEscape(artistName), p.APIKey)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	client := &http.Client{Timeout: p.FetchTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", nil, fmt.Errorf("last.fm artist.getinfo failed: status %d", resp.StatusCode)
+	}
+
+	var infoResp struct {
+		Artist struct {
+			Image []struct {
+				Text string `json:"#text"`
+				Size string `json:"size"`
+			} `json:"image"`
+		} `json:"artist"`
+		Error   int    `json:"error"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&infoResp); err != nil {
+		return "", nil, err
+	}
+	if infoResp.Error != 0 {
+		return "", nil, ErrArtistNotFound
+	}
+
+	var bestURL string
+	for _, img := range infoResp.Artist.Image {
+		if img.Text == "" {
+			continue
+		}
+		bestURL = img.Text
+		if img.Size == "mega" {
+			break
+		}
+	}
+	if bestURL == "" {
+		return "", nil, ErrArtistNotFound
+	}
+
+	meta := ProviderMeta{"quality": "40"}
+	return bestURL, meta, nil
+}
+
+/// WikipediaProvider looks up the English Wikipedia page titled after the artist name
+/// and returns its thumbnail image via the REST summary endpoint. Wikidata is not
+/// consulted
+type WikipediaProvider struct {
+	FetchTimeout time.Duration
+	MinQuality   int
+}
+
+func (p *WikipediaProvider) Name() string { return "wikipedia" }
+func (p *WikipediaProvider) Timeout() time.Duration { return p.FetchTimeout }
+func (p *WikipediaProvider) MinQualityThreshold() int { return p.MinQuality }
+
+func (p *WikipediaProvider) Fetch(ctx context.Context, artistName string) (string, ProviderMeta, error) {
+	summaryURL := fmt.Sprintf("https://en.wikipedia.org/api/rest_v1/page/summary/%s", url.PathEscape(strings.ReplaceAll(artistName, " ", "_")))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", summaryURL, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header.Set("User-Agent", "artist-image-service/1.0")
+
+	client := &http.Client{Timeout: p.FetchTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil, ErrArtistNotFound
+	}
+	if resp.StatusCode != 200 {
+		return "", nil, fmt.Errorf("wikipedia summary lookup failed: status %d", resp.StatusCode)
+	}
+
+	var summaryResp struct {
+		Thumbnail struct {
+			Source string `json:"source"`
+			Width  int    `json:"width"`
+			Height int    `json:"height"`
+		} `json:"thumbnail"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&summaryResp); err != nil {
+		return "", nil, err
+	}
+	if summaryResp.Thumbnail.Source == "" {
+		return "", nil, ErrArtistNotFound
+	}
+
+	meta := ProviderMeta{
+		"quality": "20",
+		"width":   strconv.Itoa(summaryResp.Thumbnail.Width),
+		"height":  strconv.Itoa(summaryResp.Thumbnail.Height),
+	}
+	return summaryResp.Thumbnail.Source, meta, nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+//clientIdleTTL is how long a client IP's token bucket is kept around after its last
+//request before being evicted, bounding memory use under a churning/spoofed IP set
+const clientIdleTTL = 10 * time.Minute
+
+//limiterEntry pairs a client's token bucket with the last time it was touched, so
+//evictIdleClients can reclaim entries nobody has used in a while
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+/// RateLimiter throttles outbound provider traffic and inbound client traffic using
+/// independent token buckets: one shared bucket per upstream provider, and one bucket
+/// per client IP created lazily on first use and evicted after clientIdleTTL of
+/// inactivity. X-Forwarded-For is only honored from configured trusted proxies, so a
+/// client can't spoof its way around its own limiter or inflate the client map
+type RateLimiter struct {
+	providerRPS    rate.Limit
+	providerBurst  int
+	clientRPS      rate.Limit
+	clientBurst    int
+	trustedProxies []*net.IPNet
+
+	mu        sync.Mutex
+	providers map[string]*rate.Limiter
+	clients   map[string]*limiterEntry
+}
+
+/// NewRateLimiter builds a RateLimiter from the configured provider/client RPS/burst
+/// and trusted proxy CIDRs, and starts its background idle-client eviction loop
+func NewRateLimiter(cfg *Config) *RateLimiter {
+	r := &RateLimiter{
+		providerRPS:    rate.Limit(cfg.ProviderRateLimitRPS),
+		providerBurst:  cfg.ProviderRateLimitBurst,
+		clientRPS:      rate.Limit(cfg.ClientRateLimitRPS),
+		clientBurst:    cfg.ClientRateLimitBurst,
+		trustedProxies: parseTrustedProxies(cfg.TrustedProxyCIDRs),
+		providers:      make(map[string]*rate.Limiter),
+		clients:        make(map[string]*limiterEntry),
+	}
+	go r.evictIdleClients()
+	return r
+}
+
+//parseTrustedProxies parses the configured CIDR strings, logging and skipping any
+//that fail to parse rather than failing startup over a typo
+func parseTrustedProxies(cidrs []string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			slog.Warn("ignoring invalid trusted proxy CIDR", "cidr", cidr, "error", err)
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+//isTrustedProxy reports whether the given IP (no port) falls within a configured
+//trusted proxy CIDR
+func (r *RateLimiter) isTrustedProxy(ipStr string) bool {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range r.trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+//providerLimiter returns (creating if necessary) the shared token bucket for a provider
+func (r *RateLimiter) providerLimiter(name string) *rate.Limiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l, ok := r.providers[name]
+	if !ok {
+		l = rate.NewLimiter(r.providerRPS, r.providerBurst)
+		r.providers[name] = l
+	}
+	return l
+}
+
+//clientLimiter returns (creating if necessary) the token bucket for a client IP and
+//refreshes its last-seen time so it isn't evicted while still in use
+func (r *RateLimiter) clientLimiter(ip string) *rate.Limiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.clients[ip]
+	if !ok {
+		entry = &limiterEntry{limiter: rate.NewLimiter(r.clientRPS, r.clientBurst)}
+		r.clients[ip] = entry
+	}
+	entry.lastSeen = time.Now()
+	return entry.limiter
+}
+
+//evictIdleClients periodically drops client limiters that haven't been touched in
+//clientIdleTTL, so a churning or spoofed set of client IPs can't grow the map forever
+func (r *RateLimiter) evictIdleClients() {
+	ticker := time.NewTicker(clientIdleTTL / 2)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cutoff := time.Now().Add(-clientIdleTTL)
+		r.mu.Lock()
+		for ip, entry := range r.clients {
+			if entry.lastSeen.Before(cutoff) {
+				delete(r.clients, ip)
+			}
+		}
+		r.mu.Unlock()
+	}
+}
+
+/// WaitForProvider blocks until the named provider's shared bucket has a token to
+/// spend, or ctx is done. Unlike AllowClient this blocks rather than rejects, since
+/// an upstream scrape is worth a short delay but a rejected client request isn't
+func (r *RateLimiter) WaitForProvider(ctx context.Context, name string) error {
+	return r.providerLimiter(name).Wait(ctx)
+}
+
+/// AllowClient reports whether the request's client IP still has budget this tick,
+/// without blocking
+func (r *RateLimiter) AllowClient(req *http.Request) bool {
+	return r.clientLimiter(r.clientIP(req)).Allow()
+}
+
+//clientIP extracts the request's client IP. X-Forwarded-For is only trusted when the
+//direct connection comes from a configured trusted proxy; otherwise a client could
+//pick any IP it likes to dodge its own limiter and inflate the client map
+func (r *RateLimiter) clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+
+	if fwd := req.Header.Get("X-Forwarded-For"); fwd != "" && r.isTrustedProxy(host) {
+		//Walk from the right, since entries left of the last trusted hop are whatever
+		//the client chose to send; the first untrusted address is the real client
+		parts := strings.Split(fwd, ",")
+		for i := len(parts) - 1; i >= 0; i-- {
+			candidate := strings.TrimSpace(parts[i])
+			if candidate == "" || (i > 0 && r.isTrustedProxy(candidate)) {
+				continue
+			}
+			return candidate
+		}
+	}
+
+	return host
+}
+
+/// rateLimitMiddleware rejects requests once the client IP's token bucket is empty,
+/// returning 429 so well-behaved clients back off instead of piling on upstream
+/// providers
+func rateLimitMiddleware(limiter *RateLimiter, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !limiter.AllowClient(r) {
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
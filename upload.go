@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	minPartSize         = 5 * 1024 * 1024
+	maxImageDimension   = 4096
+	uploadStagingPrefix = "uploads/"
+)
+
+/// Composer is implemented by object stores that can assemble previously uploaded
+/// parts into a single object server-side (MinIO's ComposeObject). Stores without
+/// native support fall back to streaming the parts through this process
+type Composer interface {
+	ComposeObject(ctx context.Context, destKey string, partKeys []string, contentType string) error
+}
+
+//uploadSession tracks the progress of a chunked upload across requests, persisted
+//in SQLite so it survives a restart between chunks
+type uploadSession struct {
+	id             string
+	artistName     string
+	totalLength    int64
+	receivedLength int64
+	contentType    string
+	partKeys       []string
+}
+
+func (s *ArtistImageService) loadUploadSession(ctx context.Context, id string) (*uploadSession, error) {
+	var sess uploadSession
+	var partKeysRaw string
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, artist_name, total_length, received_length, content_type, part_keys
+		FROM upload_sessions
+		WHERE id = ?
+	`, id).Scan(&sess.id, &sess.artistName, &sess.totalLength, &sess.receivedLength, &sess.contentType, &partKeysRaw)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if partKeysRaw != "" {
+		sess.partKeys = strings.Split(partKeysRaw, ",")
+	}
+	return &sess, nil
+}
+
+func (s *ArtistImageService) saveUploadSession(ctx context.Context, sess *uploadSession) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO upload_sessions
+			(id, artist_name, total_length, received_length, content_type, part_keys, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, sess.id, sess.artistName, sess.totalLength, sess.receivedLength, sess.contentType, strings.Join(sess.partKeys, ","), time.Now().Unix())
+	return err
+}
+
+func (s *ArtistImageService) deleteUploadSession(ctx context.Context, id string) {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM upload_sessions WHERE id = ?", id); err != nil {
+		slog.Warn("failed to delete upload session", "session_id", id, "error", err)
+	}
+}
+
+func newUploadID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+/// handleUploadArtistImage accepts a user-supplied artist image as either a single
+/// multipart/form-data request or a sequence of chunked requests following an
+/// Upload-Offset/Upload-Length protocol, and stores the result as an override with
+/// Source="user", invalidating any existing cache entry for the artist
+func (s *ArtistImageService) handleUploadArtistImage(w http.ResponseWriter, r *http.Request) {
+	if s.uploadAPIKey == "" {
+		s.sendJSONResponse(w, http.StatusServiceUnavailable, APIResponse{Success: false, Error: "uploads are not configured"})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+s.uploadAPIKey {
+		s.sendJSONResponse(w, http.StatusUnauthorized, APIResponse{Success: false, Error: "missing or invalid upload credentials"})
+		return
+	}
+
+	artistName := r.URL.Query().Get("name")
+	if artistName == "" {
+		s.sendJSONResponse(w, http.StatusBadRequest, APIResponse{Success: false, Error: "artist name is required"})
+		return
+	}
+
+	if r.Header.Get("Upload-Length") != "" {
+		s.handleChunkedUpload(w, r, artistName)
+		return
+	}
+
+	s.handleSingleShotUpload(w, r, artistName)
+}
+
+//handleSingleShotUpload covers the common case: one multipart/form-data request
+//carrying the whole image in a "file" field
+func (s *ArtistImageService) handleSingleShotUpload(w http.ResponseWriter, r *http.Request, artistName string) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUploadSizeBytes)
+
+	if err := r.ParseMultipartForm(minPartSize); err != nil {
+		s.sendJSONResponse(w, http.StatusBadRequest, APIResponse{Success: false, Error: fmt.Sprintf("invalid upload: %v", err)})
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		s.sendJSONResponse(w, http.StatusBadRequest, APIResponse{Success: false, Error: `missing "file" form field`})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		s.sendJSONResponse(w, http.StatusBadRequest, APIResponse{Success: false, Error: fmt.Sprintf("failed to read upload: %v", err)})
+		return
+	}
+
+	contentType := header.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "image/jpeg"
+	}
+
+	if err := validateImagePayload(data); err != nil {
+		s.sendJSONResponse(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	imageKey, err := s.storeUserImage(r.Context(), artistName, data, contentType)
+	if err != nil {
+		s.sendJSONResponse(w, http.StatusInternalServerError, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success:    true,
+		ImageURL:   s.publicImageURL(imageKey),
+		Source:     "user",
+		CachedAt:   time.Now(),
+		ArtistName: artistName,
+	})
+}
+
+//handleChunkedUpload implements a tus-style resumable protocol: the caller sends
+//an Upload-Length with the first chunk, gets back an Upload-Id, and PATCHes
+//successive chunks tagged with the matching Upload-Offset until the upload completes.
+//Every chunk request must carry Upload-Length as well, since that header is what
+//routes a request here rather than to the single-shot multipart path
+func (s *ArtistImageService) handleChunkedUpload(w http.ResponseWriter, r *http.Request, artistName string) {
+	ctx := r.Context()
+
+	totalLength, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || totalLength <= 0 {
+		s.sendJSONResponse(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid Upload-Length header"})
+		return
+	}
+	if totalLength > s.maxUploadSizeBytes {
+		s.sendJSONResponse(w, http.StatusRequestEntityTooLarge, APIResponse{Success: false, Error: "upload exceeds maximum allowed size"})
+		return
+	}
+
+	sessionID := r.Header.Get("Upload-Id")
+	var sess *uploadSession
+	if sessionID != "" {
+		sess, err = s.loadUploadSession(ctx, sessionID)
+		if err != nil {
+			s.sendJSONResponse(w, http.StatusInternalServerError, APIResponse{Success: false, Error: err.Error()})
+			return
+		}
+	}
+	if sess == nil {
+		contentType := r.Header.Get("Content-Type")
+		if contentType == "" {
+			contentType = "image/jpeg"
+		}
+		sess = &uploadSession{id: newUploadID(), artistName: artistName, totalLength: totalLength, contentType: contentType}
+		sessionID = sess.id
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset != sess.receivedLength {
+		s.sendJSONResponse(w, http.StatusConflict, APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("expected Upload-Offset %d, got %q", sess.receivedLength, r.Header.Get("Upload-Offset")),
+		})
+		return
+	}
+
+	chunk, err := io.ReadAll(io.LimitReader(r.Body, minPartSize+1))
+	if err != nil {
+		s.sendJSONResponse(w, http.StatusBadRequest, APIResponse{Success: false, Error: fmt.Sprintf("failed to read chunk: %v", err)})
+		return
+	}
+	if int64(len(chunk)) > minPartSize {
+		s.sendJSONResponse(w, http.StatusBadRequest, APIResponse{Success: false, Error: fmt.Sprintf("chunk exceeds the %d byte part size limit", minPartSize)})
+		return
+	}
+
+	partKey := fmt.Sprintf("%s%s/part-%05d", uploadStagingPrefix, sessionID, len(sess.partKeys))
+	if err := s.store.Put(ctx, partKey, bytes.NewReader(chunk), int64(len(chunk)), "application/octet-stream"); err != nil {
+		s.sendJSONResponse(w, http.StatusInternalServerError, APIResponse{Success: false, Error: fmt.Sprintf("failed to stage chunk: %v", err)})
+		return
+	}
+	sess.partKeys = append(sess.partKeys, partKey)
+	sess.receivedLength += int64(len(chunk))
+
+	if sess.receivedLength > sess.totalLength {
+		if err := s.store.Delete(ctx, partKey); err != nil {
+			slog.Warn("failed to clean up rejected upload part", "part_key", partKey, "error", err)
+		}
+		s.sendJSONResponse(w, http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("received %d bytes, exceeding declared Upload-Length of %d", sess.receivedLength, sess.totalLength),
+		})
+		return
+	}
+
+	if sess.receivedLength < sess.totalLength {
+		if err := s.saveUploadSession(ctx, sess); err != nil {
+			s.sendJSONResponse(w, http.StatusInternalServerError, APIResponse{Success: false, Error: err.Error()})
+			return
+		}
+		w.Header().Set("Upload-Id", sessionID)
+		w.Header().Set("Upload-Offset", strconv.FormatInt(sess.receivedLength, 10))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	imageKey, err := s.completeChunkedUpload(ctx, sess)
+	if err != nil {
+		s.sendJSONResponse(w, http.StatusInternalServerError, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success:    true,
+		ImageURL:   s.publicImageURL(imageKey),
+		Source:     "user",
+		CachedAt:   time.Now(),
+		ArtistName: artistName,
+	})
+}
+
+//completeChunkedUpload assembles the staged parts into the final photo object,
+//validates it, and atomically replaces the cached entry for the artist
+func (s *ArtistImageService) completeChunkedUpload(ctx context.Context, sess *uploadSession) (string, error) {
+	safeArtistName := strings.ReplaceAll(sess.artistName, "/", "_")
+	safeArtistName = strings.ReplaceAll(safeArtistName, "\\", "_")
+	imageKey := fmt.Sprintf("%s_user_%d%s", safeArtistName, time.Now().UnixNano(), extensionForContentType(sess.contentType))
+	destKey := photoPrefix + imageKey
+
+	if err := s.composeParts(ctx, destKey, sess.partKeys, sess.contentType); err != nil {
+		return "", fmt.Errorf("failed to assemble uploaded parts: %w", err)
+	}
+
+	obj, err := s.store.Get(ctx, destKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to read assembled upload: %w", err)
+	}
+	data, err := io.ReadAll(obj)
+	obj.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to read assembled upload: %w", err)
+	}
+
+	if err := validateImagePayload(data); err != nil {
+		_ = s.store.Delete(ctx, destKey)
+		return "", err
+	}
+
+	cached := &CachedImage{
+		ArtistName: sess.artistName,
+		ImageKey:   imageKey,
+		URL:        s.publicImageURL(imageKey),
+		Source:     "user",
+		FetchedAt:  time.Now(),
+	}
+	if err := s.cache.Set(ctx, cached); err != nil {
+		return "", fmt.Errorf("failed to invalidate cache for uploaded image: %w", err)
+	}
+
+	for _, partKey := range sess.partKeys {
+		if err := s.store.Delete(ctx, partKey); err != nil {
+			slog.Warn("failed to clean up upload part", "part_key", partKey, "error", err)
+		}
+	}
+	s.deleteUploadSession(ctx, sess.id)
+	s.PrewarmCommonSizes(imageKey)
+
+	return imageKey, nil
+}
+
+//composeParts assembles the given parts into destKey, using the store's native
+//Composer when available and falling back to streaming the parts through this
+//process and re-uploading them as a single object otherwise
+func (s *ArtistImageService) composeParts(ctx context.Context, destKey string, partKeys []string, contentType string) error {
+	if len(partKeys) == 1 {
+		obj, err := s.store.Get(ctx, partKeys[0])
+		if err != nil {
+			return err
+		}
+		defer obj.Close()
+
+		data, err := io.ReadAll(obj)
+		if err != nil {
+			return err
+		}
+		return s.store.Put(ctx, destKey, bytes.NewReader(data), int64(len(data)), contentType)
+	}
+
+	if composer, ok := s.store.(Composer); ok {
+		return composer.ComposeObject(ctx, destKey, partKeys, contentType)
+	}
+
+	var buf bytes.Buffer
+	for _, partKey := range partKeys {
+		obj, err := s.store.Get(ctx, partKey)
+		if err != nil {
+			return err
+		}
+		_, copyErr := io.Copy(&buf, obj)
+		obj.Close()
+		if copyErr != nil {
+			return copyErr
+		}
+	}
+	return s.store.Put(ctx, destKey, bytes.NewReader(buf.Bytes()), int64(buf.Len()), contentType)
+}
+
+//storeUserImage uploads a complete, already-validated image and invalidates the
+//artist's cache entry so GetArtistImage immediately returns the new version
+func (s *ArtistImageService) storeUserImage(ctx context.Context, artistName string, data []byte, contentType string) (string, error) {
+	safeArtistName := strings.ReplaceAll(artistName, "/", "_")
+	safeArtistName = strings.ReplaceAll(safeArtistName, "\\", "_")
+	imageKey := fmt.Sprintf("%s_user_%d%s", safeArtistName, time.Now().UnixNano(), extensionForContentType(contentType))
+
+	if err := s.store.Put(ctx, photoPrefix+imageKey, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		return "", fmt.Errorf("failed to store uploaded image: %w", err)
+	}
+
+	cached := &CachedImage{
+		ArtistName: artistName,
+		ImageKey:   imageKey,
+		URL:        s.publicImageURL(imageKey),
+		Source:     "user",
+		FetchedAt:  time.Now(),
+	}
+	if err := s.cache.Set(ctx, cached); err != nil {
+		return "", fmt.Errorf("failed to invalidate cache for uploaded image: %w", err)
+	}
+
+	s.PrewarmCommonSizes(imageKey)
+	return imageKey, nil
+}
+
+//validateImagePayload rejects payloads that aren't decodable images or that exceed
+//the maximum allowed width/height
+func validateImagePayload(data []byte) error {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("unrecognized image format: %w", err)
+	}
+	if cfg.Width > maxImageDimension || cfg.Height > maxImageDimension {
+		return fmt.Errorf("image dimensions %dx%d exceed the %dx%d limit", cfg.Width, cfg.Height, maxImageDimension, maxImageDimension)
+	}
+	return nil
+}
+
+func extensionForContentType(contentType string) string {
+	switch {
+	case strings.Contains(contentType, "png"):
+		return ".png"
+	case strings.Contains(contentType, "webp"):
+		return ".webp"
+	default:
+		return ".jpg"
+	}
+}